@@ -0,0 +1,132 @@
+package berkeley
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Aggregator calcula, a partir de las muestras de los participantes de una ronda, la diferencia de tiempo
+// objetivo respecto al líder. Cada muestra es un FollowerInfo con su diferencia (DiffTime) y su tiempo de
+// viaje (TripTime); el propio líder participa como una muestra más con diferencia cero y el tiempo de
+// viaje del seguidor más cercano.
+// El líder corrige después a cada participante con la diferencia objetivo menos su propia diferencia.
+type Aggregator interface {
+	Aggregate(samples map[string]*FollowerInfo) (int64, error)
+}
+
+// Nombres de las estrategias de agregación que se pueden seleccionar desde la configuración.
+const (
+	MeanAggregation        = "mean"
+	MedianAggregation      = "median"
+	RTTWeightedAggregation = "rtt_weighted"
+	ReferenceAggregation   = "reference"
+	ConvergenceAggregation = "convergence"
+	MarzulloAggregation    = "marzullo"
+)
+
+// errNoSamples se devuelve cuando no hay muestras sobre las que calcular la diferencia objetivo.
+var errNoSamples = errors.New("no hay muestras para calcular la diferencia objetivo")
+
+// NewAggregator crea la estrategia de agregación indicada por su nombre. Un nombre vacío selecciona la
+// media. La estrategia "reference" necesita el nombre del nodo de referencia y la estrategia
+// "convergence" la cota de lectura (bound).
+func NewAggregator(name, reference string, bound time.Duration) (Aggregator, error) {
+	switch name {
+	case "", MeanAggregation:
+		return MeanAggregator{}, nil
+	case MedianAggregation:
+		return MedianAggregator{}, nil
+	case RTTWeightedAggregation:
+		return RTTWeightedMeanAggregator{}, nil
+	case ReferenceAggregation:
+		if reference == "" {
+			return nil, errors.New("la agregación por nodo de referencia necesita un nodo de referencia")
+		}
+		return ReferenceAggregator{Reference: reference}, nil
+	case ConvergenceAggregation:
+		if bound <= 0 {
+			return nil, errors.New("la agregación por convergencia interactiva necesita una cota de lectura mayor que cero")
+		}
+		return ConvergenceAggregator{Bound: bound}, nil
+	case MarzulloAggregation:
+		return MarzulloAggregator{}, nil
+	default:
+		return nil, fmt.Errorf("estrategia de agregación desconocida: %s", name)
+	}
+}
+
+// MeanAggregator toma como objetivo la media de las diferencias, como el algoritmo de Berkeley clásico.
+type MeanAggregator struct{}
+
+// Aggregate implementa Aggregator.
+func (MeanAggregator) Aggregate(samples map[string]*FollowerInfo) (int64, error) {
+	if len(samples) == 0 {
+		return 0, errNoSamples
+	}
+	var sum int64 = 0
+	for _, sample := range samples {
+		sum += sample.DiffTime
+	}
+	return sum / int64(len(samples)), nil
+}
+
+// MedianAggregator toma como objetivo la mediana de las diferencias, insensible a unos pocos relojes extremos.
+type MedianAggregator struct{}
+
+// Aggregate implementa Aggregator.
+func (MedianAggregator) Aggregate(samples map[string]*FollowerInfo) (int64, error) {
+	if len(samples) == 0 {
+		return 0, errNoSamples
+	}
+	diffs := make([]int64, 0, len(samples))
+	for _, sample := range samples {
+		diffs = append(diffs, sample.DiffTime)
+	}
+	sort.Slice(diffs, func(i, j int) bool { return diffs[i] < diffs[j] })
+
+	middle := len(diffs) / 2
+	if len(diffs)%2 == 0 {
+		return (diffs[middle-1] + diffs[middle]) / 2, nil
+	}
+	return diffs[middle], nil
+}
+
+// RTTWeightedMeanAggregator toma como objetivo la media de las diferencias ponderada por la inversa del
+// tiempo de viaje: las muestras con menos retardo, y por tanto menos incertidumbre, pesan más.
+type RTTWeightedMeanAggregator struct{}
+
+// Aggregate implementa Aggregator.
+func (RTTWeightedMeanAggregator) Aggregate(samples map[string]*FollowerInfo) (int64, error) {
+	if len(samples) == 0 {
+		return 0, errNoSamples
+	}
+	var weightedSum, totalWeight float64
+	for _, sample := range samples {
+		tripTime := sample.TripTime
+		if tripTime < 0 {
+			tripTime = 0
+		}
+		// Se suma uno para que un tiempo de viaje nulo no produzca un peso infinito
+		weight := 1 / float64(tripTime+1)
+		weightedSum += weight * float64(sample.DiffTime)
+		totalWeight += weight
+	}
+	return int64(weightedSum / totalWeight), nil
+}
+
+// ReferenceAggregator toma como objetivo la diferencia del nodo de referencia: todos los relojes se
+// alinean con el suyo. Si la referencia es el propio líder el objetivo es cero.
+type ReferenceAggregator struct {
+	Reference string // Nombre del nodo cuyo reloj se toma como referencia
+}
+
+// Aggregate implementa Aggregator.
+func (a ReferenceAggregator) Aggregate(samples map[string]*FollowerInfo) (int64, error) {
+	sample, ok := samples[a.Reference]
+	if !ok {
+		return 0, fmt.Errorf("el nodo de referencia %s no tiene una muestra válida en esta ronda", a.Reference)
+	}
+	return sample.DiffTime, nil
+}
@@ -0,0 +1,40 @@
+package berkeley
+
+import "testing"
+
+func TestAggregators(t *testing.T) {
+	samples := map[string]*FollowerInfo{
+		"L": {DiffTime: 0, TripTime: 0},
+		"A": {DiffTime: 10, TripTime: 9},
+		"B": {DiffTime: 20, TripTime: 1},
+		"C": {DiffTime: 90, TripTime: 99},
+	}
+	tests := []struct {
+		name       string
+		aggregator Aggregator
+		want       int64
+	}{
+		{name: "media", aggregator: MeanAggregator{}, want: 30},
+		{name: "mediana con número par", aggregator: MedianAggregator{}, want: 15},
+		{name: "media ponderada por el viaje", aggregator: RTTWeightedMeanAggregator{}, want: 7},
+		{name: "referencia", aggregator: ReferenceAggregator{Reference: "B"}, want: 20},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.aggregator.Aggregate(samples)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("objetivo %d, se esperaba %d", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := (ReferenceAggregator{Reference: "X"}).Aggregate(samples); err == nil {
+		t.Error("con una referencia sin muestra se esperaba un error")
+	}
+	if _, err := (MeanAggregator{}).Aggregate(nil); err == nil {
+		t.Error("sin muestras se esperaba un error")
+	}
+}
@@ -1,11 +1,10 @@
 package berkeley
 
 import (
-	"errors"
+	"context"
 	"log"
+	"net"
 	"time"
-
-	zmq "github.com/pebbe/zmq4" // Librería para trabajar con ZeroMQ
 )
 
 // Interfaz Handler con el método HandleProcess
@@ -13,32 +12,56 @@ type Handler interface {
 	HandleProcess(message string) (string, error)
 }
 
+// AsyncHandler lo implementan los handlers que reciben mensajes sin respuesta, enviados con
+// SendMessageAsync. Los mensajes asíncronos dirigidos a un nodo cuyo handler no lo implementa se descartan.
+type AsyncHandler interface {
+	HandleAsync(message string)
+}
+
 // AbstractNode proporciona la funcionalidad base para nodos en el sistema.
 type AbstractNode struct {
 	Name          string
 	Address       string
 	Timeout       time.Duration
 	NodeAddresses map[string]string
-	Context       *zmq.Context
-	Socket        *zmq.Socket
+	Transport     Transport // Medio por el que el nodo intercambia mensajes
 	Logger        *log.Logger
 	Handler       // Composición de la interfaz Handler
+
+	// RetryPolicy es la política de reintentos de SendMessageSync.
+	RetryPolicy RetryPolicy
+	// Workers es el número de goroutines que atienden a la vez los mensajes recibidos
+	// (DefaultListenerWorkers si es 0). Con 1 los mensajes se atienden de uno en uno.
+	Workers int
+	// ProbeTimeout es la espera de cada sonda GET_TIME enviada por UDP. Con 0 las peticiones de tiempo
+	// van por el transporte como el resto de mensajes.
+	ProbeTimeout time.Duration
+
+	probeConn *net.UDPConn // Socket de las sondas UDP atendidas, si se inició su escucha
 }
 
-// NewAbstractNode crea e inicializa un nuevo nodo base.
+// DefaultListenerWorkers es el número de goroutines que atienden mensajes si no se indica otro.
+const DefaultListenerWorkers = 4
+
+// NewAbstractNode crea e inicializa un nuevo nodo base sobre un transporte creado con DefaultTransport.
 func NewAbstractNode(name, address string, timeout time.Duration) (*AbstractNode, error) {
-	context, err := zmq.NewContext()
+	transport, err := DefaultTransport(timeout * time.Millisecond)
 	if err != nil {
-		return nil, errors.New("error al crear el contexto ZeroMQ")
+		return nil, err
 	}
+	return NewAbstractNodeWithTransport(name, address, timeout, transport), nil
+}
 
+// NewAbstractNodeWithTransport crea e inicializa un nuevo nodo base sobre el transporte indicado.
+func NewAbstractNodeWithTransport(name, address string, timeout time.Duration, transport Transport) *AbstractNode {
 	return &AbstractNode{
-		Name:    name,
-		Address: address,
-		Timeout: timeout,
-		Context: context,
-		Logger:  log.Default(),
-	}, nil
+		Name:        name,
+		Address:     address,
+		Timeout:     timeout,
+		Transport:   transport,
+		Logger:      log.Default(),
+		RetryPolicy: DefaultRetryPolicy,
+	}
 }
 
 // InitializeNodeWithAddresses inicializa un nodo con direcciones de otros nodos.
@@ -54,151 +77,112 @@ func InitializeNodeWithAddresses(name, address string, timeout time.Duration, ad
 }
 
 // SendMessageSync envía un mensaje de forma síncrona y espera una respuesta.
+// El mensaje viaja por la conexión que el transporte mantiene abierta hacia esa dirección, de modo que
+// los intercambios sucesivos con el mismo nodo no pagan una nueva conexión. Si el intercambio falla, por
+// ejemplo porque la respuesta no llega a tiempo, la conexión se descarta y el mensaje se reenvía por una
+// nueva según la RetryPolicy del nodo.
 func (n *AbstractNode) SendMessageSync(address string, message string) (string, error) {
-	// Creación del socket REQ (Request) para enviar el mensaje
-	var socket *zmq.Socket
-	socket, err := n.Context.NewSocket(zmq.REQ)
-	if err != nil {
-		n.Logger.Printf("Error al crear el socket REQ: %v", err) // Traza adicional
-		return "", errors.New("error al crear el socket REQ")
-	}
-	defer socket.Close()
-
-	// Establecer el timeout para recibir respuestas
-	socket.SetRcvtimeo(n.Timeout * time.Millisecond) // Convertir el timeout a milisegundos
-
-	// Traza: Mostrar el valor del timeout configurado
-	n.Logger.Printf("Timeout de recepción configurado a: %v milisegundos", int64(n.Timeout)) // Traza para el timeout
-
-	// Conectar al socket en la dirección proporcionada
-	err = socket.Connect("tcp://" + address)
-	if err != nil {
-		n.Logger.Printf("Error al conectar con %s: %v", address, err) // Traza adicional
-		return "", errors.New("error al conectar con " + address)
-	}
+	reply, _, err := n.SendMessageSyncAttempts(context.Background(), address, message)
+	return reply, err
+}
 
-	n.Logger.Printf("Conectado a %s", address) // Traza para verificar la conexión
+// SendMessageSyncAttempts es como SendMessageSync, pero devuelve además el número de intentos realizados
+// y deja de reintentar si se cancela el contexto.
+func (n *AbstractNode) SendMessageSyncAttempts(ctx context.Context, address string, message string) (string, int, error) {
+	n.Logger.Printf("Enviando mensaje a %s con un timeout de recepción de %v milisegundos", address, int64(n.Timeout)) // Traza para el timeout
 
-	// Enviar el mensaje al servidor
-	_, err = socket.Send(message, 0)
+	reply, attempts, err := n.sendWithRetries(ctx, address, func(int) (string, error) { return message, nil })
 	if err != nil {
-		n.Logger.Printf("Error al enviar el mensaje a %s: %v", address, err) // Traza adicional
-		return "", errors.New("error al enviar el mensaje")
+		n.Logger.Printf("Error en el intercambio con %s: %v", address, err) // Traza para el error
+		return "", attempts, err
 	}
 
-	n.Logger.Printf("Mensaje enviado a %s: %s", address, message) // Traza de envío
+	n.Logger.Printf("Respuesta recibida de %s: %s", address, reply) // Traza de respuesta recibida
+	return reply, attempts, nil
+}
 
-	// Intentar recibir la respuesta
-	reply, err := socket.Recv(0)
+// SendMessageOnce envía un mensaje de manera sincrónica con un único intento, sin la RetryPolicy del
+// nodo. Es para los mensajes que pierden su valor si se retrasan, como los de la elección de líder: un
+// nodo caído debe detectarse en un timeout, no tras todos los reintentos.
+func (n *AbstractNode) SendMessageOnce(address string, message string) (string, error) {
+	reply, _, err := n.sendWithPolicy(context.Background(), address, RetryPolicy{Attempts: 1}, func(int) (string, error) { return message, nil })
 	if err != nil {
-		n.Logger.Printf("Error al recibir respuesta de %s: %v", address, err) // Traza para el error de recepción
-		return "", errors.New("no se recibió respuesta del socket")
+		n.Logger.Printf("Error en el intercambio con %s: %v", address, err)
+		return "", err
 	}
-
-	n.Logger.Printf("Respuesta recibida de %s: %s", address, reply) // Traza de respuesta recibida
 	return reply, nil
 }
 
-// SendMessageAsync envía un mensaje de manera asíncrona.
+// CloseConnection cierra la conexión abierta hacia una dirección, si la había. El siguiente mensaje a
+// esa dirección abrirá una nueva.
+func (n *AbstractNode) CloseConnection(address string) {
+	n.Transport.Drop(address)
+}
+
+// SendMessageAsync envía un mensaje de manera asíncrona, sin esperar respuesta. El nodo de destino lo
+// recibe en el HandleAsync de su handler.
 func (n *AbstractNode) SendMessageAsync(address, message string) error {
-	socket, err := n.Context.NewSocket(zmq.PUSH)
-	if err != nil {
-		return errors.New("error al crear el socket PUSH")
+	if err := n.Transport.Send(address, message); err != nil {
+		return err
 	}
-	defer socket.Close()
 
-	err = socket.Connect("tcp://" + address)
-	if err != nil {
-		return errors.New("error al conectar con " + address)
-	}
+	n.Logger.Printf("Mensaje asincrónico enviado a %s: %s", address, message)
+	return nil
+}
 
-	_, err = socket.Send(message, 0)
-	if err != nil {
-		return errors.New("error al enviar el mensaje")
+// AsyncReply es la respuesta a una petición enviada con SendRequestAsync.
+type AsyncReply struct {
+	Address string // Dirección a la que se envió la petición
+	Reply   string // Respuesta recibida, vacía si hubo un error
+	Err     error  // Error del intercambio, nil si llegó la respuesta
+}
+
+// SendRequestAsync envía una petición sin esperar la respuesta, que se entrega más tarde en el canal
+// devuelto. Así el líder puede lanzar una petición a cada seguidor y recoger las respuestas según llegan.
+func (n *AbstractNode) SendRequestAsync(address, message string) <-chan AsyncReply {
+	n.Logger.Printf("Petición asíncrona enviada a %s: %s", address, message)
+	if async, ok := n.Transport.(asyncRequester); ok {
+		return async.RequestAsync(address, message)
 	}
 
-	n.Logger.Printf("Mensaje asincrónico enviado a %s: %s", address, message)
-	return nil
+	replies := make(chan AsyncReply, 1)
+	go func() {
+		reply, err := n.Transport.Request(address, message)
+		replies <- AsyncReply{Address: address, Reply: reply, Err: err}
+	}()
+	return replies
 }
 
 // StartListening inicia el proceso de escucha para mensajes entrantes en el nodo.
-// Configura un socket de tipo REP (Response) para recibir y responder a mensajes.
+// El transporte reparte los mensajes entre Workers goroutines, de modo que una petición lenta no
+// retrasa a las demás. El Handler debe admitir llamadas concurrentes. Si además es un AsyncHandler,
+// recibe también los mensajes enviados con SendMessageAsync.
 func (n *AbstractNode) StartListening() error {
-	// Log para indicar que estamos intentando crear un socket REP.
-	n.Logger.Printf("Intentando crear un socket REP para el nodo %s en la dirección %s", n.Name, n.Address)
-	socket, err := n.Context.NewSocket(zmq.REP)
-	if err != nil {
-		// Si ocurre un error al crear el socket, loguea el error y retorna un mensaje de error.
-		n.Logger.Printf("Error al crear el socket REP: %v", err)
-		return errors.New("error al crear el socket REP")
+	workers := n.Workers
+	if workers <= 0 {
+		workers = DefaultListenerWorkers
 	}
-	n.Socket = socket
-	n.Logger.Printf("Socket REP creado exitosamente para el nodo %s", n.Name)
 
-	// Enlaza el socket a la dirección TCP proporcionada en el nodo para esperar conexiones.
-	n.Logger.Printf("Enlazando el socket REP en la dirección tcp://%s", n.Address)
-	err = socket.Bind("tcp://" + n.Address)
-	if err != nil {
-		// Si ocurre un error al enlazar el socket, loguea el error y retorna un mensaje de error.
-		n.Logger.Printf("Error al enlazar el socket en %s: %v", n.Address, err)
-		return errors.New("error al enlazar el socket en " + n.Address)
+	n.Logger.Printf("Iniciando la escucha del nodo %s en la dirección %s", n.Name, n.Address)
+	if err := n.Transport.Listen(n.Address, n.Handler, workers); err != nil {
+		n.Logger.Printf("Error al iniciar la escucha en %s: %v", n.Address, err)
+		return err
 	}
-	n.Logger.Printf("Socket enlazado exitosamente en %s", n.Address)
-
-	// Inicia una nueva goroutine para escuchar de manera concurrente sin bloquear el hilo principal.
-	n.Logger.Printf("Iniciando goroutine para escuchar en el nodo %s", n.Name)
-	go func() {
-		// Logea que el nodo ha comenzado a escuchar en la dirección configurada.
-		n.Logger.Printf("Nodo %s escuchando en %s", n.Name, n.Address)
-
-		// Entra en un bucle infinito para recibir y procesar mensajes.
-		for {
-			// Recibe un mensaje del socket.
-			n.Logger.Printf("Esperando mensaje en %s...", n.Name)
-			message, err := socket.Recv(0)
-			if err != nil {
-				// Si ocurre un error al recibir el mensaje, loguea el error y termina el bucle.
-				n.Logger.Printf("Error al recibir mensaje: %v", err)
-				break
-			}
-			n.Logger.Printf("Mensaje recibido en %s: %v", n.Name, message)
-
-			// Llama al método HandleProcess, que es implementado por el tipo real de nodo (Follower, Leader, etc.).
-			// Este método procesará el mensaje recibido y generará una respuesta.
-			//n.Logger.Printf("Procesando mensaje en %s...", n.Name)
-			response, err := n.Handler.HandleProcess(message)
-			if err != nil {
-				// Si hay un error al procesar el mensaje, loguea el error y termina el bucle.
-				n.Logger.Printf("Error en HandleProcess en %s: %v", n.Name, err)
-				break
-			}
-
-			// Envía la respuesta de vuelta al cliente a través del socket.
-			n.Logger.Printf("Enviando respuesta en %s: %v", n.Name, response)
-			_, err = socket.Send(response, 0)
-			if err != nil {
-				// Si ocurre un error al enviar la respuesta, loguea el error y termina el bucle.
-				n.Logger.Printf("Error al enviar respuesta en %s: %v", n.Name, err)
-				break
-			}
-		}
-	}()
 
-	// La función regresa nil si todo se configura correctamente y la goroutine se inicia sin errores.
-	n.Logger.Printf("Escucha iniciada exitosamente en el nodo %s", n.Name)
+	// La función regresa nil si todo se configura correctamente y la escucha queda en marcha.
+	n.Logger.Printf("Nodo %s escuchando en %s con %d trabajadores", n.Name, n.Address, workers)
 	return nil
 }
 
 // Close cierra los recursos del nodo.
 func (n *AbstractNode) Close() error {
-	if n.Socket != nil {
-		n.Socket.Close()
-		n.Logger.Printf("Socket cerrado para el nodo %s", n.Name)
+	if n.probeConn != nil {
+		n.probeConn.Close()
 	}
-	if n.Context != nil {
-		n.Context.Term()
-		n.Logger.Printf("Contexto cerrado para el nodo %s", n.Name)
+	if err := n.Transport.Close(); err != nil {
+		return err
 	}
+	n.Logger.Printf("Transporte cerrado para el nodo %s", n.Name)
 	return nil
 }
 
@@ -0,0 +1,338 @@
+package berkeley
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// Canal de difusión: si el líder tiene BroadcastAddress y su transporte es un Broadcaster, anuncia
+// cada ronda con ROUND_START y envía todas las correcciones de la ronda en un único UPDATE_BATCH, con
+// una entrada por nombre de seguidor. Cada seguidor aplica la suya y la confirma con ACK_UPDATE por el
+// canal de peticiones. Las correcciones sin confirmar a tiempo se reenvían una a una con UPDATE_TIME y
+// el mismo identificador, de modo que el seguidor que ya la aplicó no la repite.
+
+// ClusterParams son los parámetros de corrección que el líder difunde a todo el grupo al empezar cada
+// ronda. Los campos vacíos no cambian la configuración de los seguidores.
+type ClusterParams struct {
+	AdjustMode  AdjustMode `json:"adjust_mode,omitempty"`
+	MaxSlewRate float64    `json:"max_slew_rate,omitempty"` // Partes por millón
+	StepLimit   int64      `json:"step_limit,omitempty"`    // Nanosegundos
+}
+
+// RoundNotice es el anuncio de una ronda por el canal de difusión.
+type RoundNotice struct {
+	Operation  string         `json:"operation"` // ROUND_START
+	Round      int            `json:"round"`
+	LeaderAddr string         `json:"leader_address"`
+	Params     *ClusterParams `json:"params,omitempty"`
+}
+
+// CorrectionBatch son las correcciones de una ronda por el canal de difusión, indexadas por el nombre
+// del seguidor. Las correcciones van siempre en nanosegundos.
+type CorrectionBatch struct {
+	Operation   string                     `json:"operation"` // UPDATE_BATCH
+	Round       int                        `json:"round"`
+	LeaderAddr  string                     `json:"leader_address"`
+	Corrections map[string]BatchCorrection `json:"corrections"`
+}
+
+// BatchCorrection es la corrección de un seguidor dentro de un CorrectionBatch.
+type BatchCorrection struct {
+	Delta int64   `json:"delta"`
+	Rate  float64 `json:"rate,omitempty"`
+	ID    string  `json:"id"`
+}
+
+// UpdateAck es la confirmación de un seguidor de que aplicó una corrección difundida.
+type UpdateAck struct {
+	Operation    string `json:"operation"` // ACK_UPDATE
+	FollowerName string `json:"followerName"`
+	ID           string `json:"id"`
+	Round        int    `json:"round"`
+}
+
+// ackCollector reparte las confirmaciones recibidas entre las rondas que las esperan.
+type ackCollector struct {
+	mu      sync.Mutex
+	waiting map[string]chan<- UpdateAck // Canal de la ronda que espera cada identificador
+}
+
+// expect registra los identificadores de una ronda y devuelve el canal por el que llegarán sus confirmaciones.
+func (a *ackCollector) expect(ids []string) <-chan UpdateAck {
+	acks := make(chan UpdateAck, len(ids))
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.waiting == nil {
+		a.waiting = make(map[string]chan<- UpdateAck)
+	}
+	for _, id := range ids {
+		a.waiting[id] = acks
+	}
+	return acks
+}
+
+// deliver entrega una confirmación a la ronda que la espera. Devuelve false si nadie la esperaba.
+func (a *ackCollector) deliver(ack UpdateAck) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	acks, ok := a.waiting[ack.ID]
+	if !ok {
+		return false
+	}
+	delete(a.waiting, ack.ID)
+	acks <- ack
+	return true
+}
+
+// forget deja de esperar los identificadores indicados.
+func (a *ackCollector) forget(ids []string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for _, id := range ids {
+		delete(a.waiting, id)
+	}
+}
+
+// broadcaster devuelve el canal de difusión del líder, preparándolo la primera vez: comprueba que el
+// transporte lo admite, que el líder escucha en su dirección, por la que llegan las confirmaciones
+// salvo que le lleguen por otro nodo, y enlaza la dirección de difusión. Devuelve nil si el líder no
+// difunde.
+func (l *Leader) broadcaster() Broadcaster {
+	if l.BroadcastAddress == "" || l.broadcastErr != nil {
+		return nil
+	}
+	if l.broadcast != nil {
+		return l.broadcast
+	}
+
+	broadcast, ok := l.aAbstractNode.Transport.(Broadcaster)
+	if !ok {
+		l.broadcastErr = errors.New("el transporte no admite difusión")
+	} else if l.broadcastErr = l.listen(); l.broadcastErr == nil {
+		l.broadcastErr = broadcast.Bind(l.BroadcastAddress)
+	}
+	if l.broadcastErr != nil {
+		log.Printf("Canal de difusión desactivado en el líder %s: %v", l.aAbstractNode.Name, l.broadcastErr)
+		return nil
+	}
+	l.broadcast = broadcast
+	return broadcast
+}
+
+// announceRound difunde el comienzo de la ronda con los parámetros del grupo.
+func (l *Leader) announceRound() {
+	broadcast := l.broadcaster()
+	if broadcast == nil {
+		return
+	}
+	notice := RoundNotice{Operation: "ROUND_START", Round: l.Rounds, LeaderAddr: l.aAbstractNode.Address, Params: l.Params}
+	data, err := json.Marshal(notice)
+	if err != nil {
+		log.Printf("Error al serializar el anuncio de ronda: %v", err)
+		return
+	}
+	if err := broadcast.Publish(l.BroadcastAddress, string(data)); err != nil {
+		log.Printf("Error al difundir el anuncio de ronda: %v", err)
+	}
+}
+
+// broadcastCorrections difunde las correcciones de los seguidores que hablan la versión en
+// nanosegundos y espera sus confirmaciones hasta ackDeadline o hasta que se cancela el contexto.
+// Devuelve los seguidores que confirmaron, con la corrección registrada, y las correcciones que quedan
+// por enviar una a una.
+func (l *Leader) broadcastCorrections(ctx context.Context, corrections map[string]int64, ids map[string]string) (map[string]*FollowerInfo, map[string]int64) {
+	remaining := make(map[string]int64, len(corrections))
+	for name, delta := range corrections {
+		remaining[name] = delta
+	}
+	broadcast := l.broadcaster()
+	if broadcast == nil {
+		return nil, remaining
+	}
+
+	batch := CorrectionBatch{Operation: "UPDATE_BATCH", Round: l.Rounds, LeaderAddr: l.aAbstractNode.Address, Corrections: make(map[string]BatchCorrection)}
+	var batchIDs []string
+	for name, delta := range corrections {
+		follower, ok := l.SuccessfulFollowers[name]
+		if !ok || follower.Version < ProtocolVersionNano {
+			continue
+		}
+		batch.Corrections[name] = BatchCorrection{Delta: delta, Rate: follower.RateCorrection, ID: ids[name]}
+		batchIDs = append(batchIDs, ids[name])
+	}
+	if len(batchIDs) == 0 {
+		return nil, remaining
+	}
+
+	data, err := json.Marshal(batch)
+	if err != nil {
+		log.Printf("Error al serializar el lote de correcciones: %v", err)
+		return nil, remaining
+	}
+	acks := l.acks.expect(batchIDs)
+	defer l.acks.forget(batchIDs)
+	if err := broadcast.Publish(l.BroadcastAddress, string(data)); err != nil {
+		log.Printf("Error al difundir el lote de correcciones: %v", err)
+		return nil, remaining
+	}
+	log.Printf("Lote de %d correcciones difundido en %s", len(batchIDs), l.BroadcastAddress)
+
+	confirmed := make(map[string]*FollowerInfo)
+	timer := time.NewTimer(l.ackDeadline(batch.Corrections))
+	defer timer.Stop()
+	for len(confirmed) < len(batchIDs) {
+		select {
+		case ack := <-acks:
+			follower, ok := l.SuccessfulFollowers[ack.FollowerName]
+			if !ok {
+				continue
+			}
+			updated := *follower
+			updated.SetDelta(corrections[ack.FollowerName])
+			updated.State = TimeUpdated
+			confirmed[ack.FollowerName] = &updated
+			delete(remaining, ack.FollowerName)
+		case <-timer.C:
+			log.Printf("Confirmaciones del lote recibidas: %d de %d; el resto se envía una a una", len(confirmed), len(batchIDs))
+			return confirmed, remaining
+		case <-ctx.Done():
+			log.Printf("Espera de las confirmaciones del lote interrumpida: %d de %d recibidas", len(confirmed), len(batchIDs))
+			return confirmed, remaining
+		}
+	}
+	return confirmed, remaining
+}
+
+// ackDeadline es la espera de las confirmaciones de un lote: el timeout del líder, o el doble si algún
+// seguidor del lote es un sub-líder, que sólo confirma tras difundir la corrección a su grupo y esperar
+// a su vez sus confirmaciones.
+func (l *Leader) ackDeadline(corrections map[string]BatchCorrection) time.Duration {
+	timeout := l.aAbstractNode.Timeout * time.Millisecond
+	for name := range corrections {
+		if l.SubLeaders[name] {
+			return 2 * timeout
+		}
+	}
+	return timeout
+}
+
+// handleAck atiende la confirmación de una corrección difundida.
+func (l *Leader) handleAck(data map[string]interface{}) string {
+	ack := UpdateAck{Operation: "ACK_UPDATE"}
+	ack.FollowerName, _ = data["followerName"].(string)
+	ack.ID, _ = data["id"].(string)
+	if round, ok := data["round"].(float64); ok {
+		ack.Round = int(round)
+	}
+	if !l.acks.deliver(ack) {
+		log.Printf("Confirmación %s de %s fuera de plazo o desconocida", ack.ID, ack.FollowerName)
+		return `{"operation":"ACK_IGNORED"}`
+	}
+	log.Printf("✅ Confirmación de la corrección %s recibida de %s", ack.ID, ack.FollowerName)
+	return `{"operation":"ACK_OK"}`
+}
+
+// subscribe suscribe al seguidor al canal de difusión del líder.
+func (f *Follower) subscribe() error {
+	broadcast, ok := f.aAbstractNode.Transport.(Broadcaster)
+	if !ok {
+		return fmt.Errorf("el transporte no admite difusión: no se puede escuchar %s", f.BroadcastAddress)
+	}
+	log.Printf("Seguidor %s suscrito al canal de difusión %s", f.aAbstractNode.Name, f.BroadcastAddress)
+	return broadcast.Subscribe(f.BroadcastAddress, f.handleBroadcast)
+}
+
+// handleBroadcast atiende un mensaje del canal de difusión. La corrección del seguidor se aplica con el
+// Handler del nodo como si hubiera llegado en un UPDATE_TIME, para que un sub-líder la propague a su
+// grupo, y se confirma al líder por el canal de peticiones.
+func (f *Follower) handleBroadcast(message string) {
+	var header struct {
+		Operation string `json:"operation"`
+	}
+	if err := json.Unmarshal([]byte(message), &header); err != nil {
+		log.Printf("Error al deserializar el mensaje difundido: %v", err)
+		return
+	}
+
+	switch header.Operation {
+	case "ROUND_START":
+		var notice RoundNotice
+		if err := json.Unmarshal([]byte(message), &notice); err != nil {
+			log.Printf("Anuncio de ronda no válido: %v", err)
+			return
+		}
+		f.markLeaderContact()
+		log.Printf("📣 Ronda %d anunciada por %s en el seguidor %s", notice.Round, notice.LeaderAddr, f.aAbstractNode.Name)
+		if notice.Params != nil {
+			f.applyParams(*notice.Params)
+		}
+	case "UPDATE_BATCH":
+		var batch CorrectionBatch
+		if err := json.Unmarshal([]byte(message), &batch); err != nil {
+			log.Printf("Lote de correcciones no válido: %v", err)
+			return
+		}
+		correction, ok := batch.Corrections[f.aAbstractNode.Name]
+		if !ok {
+			return
+		}
+		f.applyBatchCorrection(batch, correction)
+	}
+}
+
+// applyBatchCorrection aplica la corrección difundida y la confirma al líder.
+func (f *Follower) applyBatchCorrection(batch CorrectionBatch, correction BatchCorrection) {
+	request, err := json.Marshal(DeltaRequest{
+		Message:    "Corrección difundida en el lote de la ronda.",
+		Operation:  "UPDATE_TIME",
+		Delta:      correction.Delta,
+		Rate:       correction.Rate,
+		LeaderAddr: batch.LeaderAddr,
+		Version:    ProtocolVersionNano,
+		ID:         correction.ID,
+	})
+	if err != nil {
+		log.Printf("Error al preparar la corrección difundida: %v", err)
+		return
+	}
+	reply, err := f.aAbstractNode.Handler.HandleProcess(string(request))
+	if err != nil {
+		log.Printf("Error al aplicar la corrección difundida en %s: %v", f.aAbstractNode.Name, err)
+		return
+	}
+	var response map[string]string
+	if err := json.Unmarshal([]byte(reply), &response); err != nil || response["operation"] != "OK_MOD_TIME" {
+		log.Printf("La corrección difundida no se aplicó en %s: %s", f.aAbstractNode.Name, reply)
+		return
+	}
+
+	ack, err := json.Marshal(UpdateAck{Operation: "ACK_UPDATE", FollowerName: f.aAbstractNode.Name, ID: correction.ID, Round: batch.Round})
+	if err != nil {
+		log.Printf("Error al serializar la confirmación: %v", err)
+		return
+	}
+	if _, err := f.aAbstractNode.SendMessageSync(batch.LeaderAddr, string(ack)); err != nil {
+		log.Printf("Error al confirmar la corrección %s al líder: %v", correction.ID, err)
+	}
+}
+
+// applyParams aplica los parámetros de corrección difundidos por el líder. Las correcciones se aplican
+// con el mutex de updates bloqueado, así que los parámetros se cambian también con él.
+func (f *Follower) applyParams(params ClusterParams) {
+	f.updates.mu.Lock()
+	defer f.updates.mu.Unlock()
+	if params.AdjustMode != "" {
+		f.AdjustMode = params.AdjustMode
+	}
+	if params.MaxSlewRate > 0 {
+		f.MaxSlewRate = params.MaxSlewRate
+	}
+	if params.StepLimit > 0 {
+		f.StepLimit = time.Duration(params.StepLimit)
+	}
+}
@@ -0,0 +1,44 @@
+package berkeley
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestBroadcastCorrectionsStopsWaitingOnCancel(t *testing.T) {
+	factory, err := NewTransportFactory(MemoryTransportName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous := DefaultTransport
+	DefaultTransport = factory
+	defer func() { DefaultTransport = previous }()
+
+	leader, err := InitializeLeaderNode("L", "memory:leader", 5000, map[string]string{"F": "memory:f"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer leader.Close()
+	leader.BroadcastAddress = "memory:difusion"
+	leader.initializeStructs()
+
+	// Nadie está suscrito al canal: la confirmación de F no llegará nunca
+	follower := NewFollowerInfo("memory:f", "F", 0, 0, 0, 0)
+	follower.Version = ProtocolVersionNano
+	leader.SuccessfulFollowers["F"] = follower
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	start := time.Now()
+	confirmed, remaining := leader.broadcastCorrections(ctx, map[string]int64{"F": 1000}, map[string]string{"F": "L-F-1"})
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("la espera de las confirmaciones duró %v con el contexto cancelado", elapsed)
+	}
+	if len(confirmed) != 0 {
+		t.Errorf("confirmadas %v, no se esperaba ninguna", confirmed)
+	}
+	if _, ok := remaining["F"]; !ok || len(remaining) != 1 {
+		t.Errorf("pendientes %v, se esperaba la de F", remaining)
+	}
+}
@@ -0,0 +1,101 @@
+package berkeley
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock es la fuente de tiempo de un nodo. Permite sustituir el reloj del sistema por relojes
+// desfasados, con deriva o manuales para simular el algoritmo y probarlo de forma determinista.
+// Es de sólo lectura: los nodos no corrigen su reloj base, sino un VirtualClock construido encima.
+type Clock interface {
+	// Now devuelve la hora actual del reloj.
+	Now() time.Time
+	// Monotonic devuelve el tiempo transcurrido desde un origen arbitrario, medido con una lectura
+	// monotónica: no le afectan las correcciones de paso, por lo que sirve para medir intervalos.
+	Monotonic() time.Duration
+}
+
+// processStart es el origen de las lecturas monotónicas de SystemClock.
+var processStart = time.Now()
+
+// SystemClock es el reloj del sistema.
+type SystemClock struct{}
+
+// Now implementa Clock.
+func (SystemClock) Now() time.Time {
+	return time.Now()
+}
+
+// Monotonic implementa Clock.
+func (SystemClock) Monotonic() time.Duration {
+	return time.Since(processStart)
+}
+
+// SkewedClock es un reloj del sistema con un desfase inicial y una deriva, para simular nodos cuyos
+// relojes no están de acuerdo.
+type SkewedClock struct {
+	mu     sync.Mutex
+	offset time.Duration // Desfase respecto al reloj del sistema en el instante start
+	drift  float64       // Deriva en partes por millón: positiva adelanta, negativa atrasa
+	start  time.Time     // Instante de creación del reloj
+}
+
+// NewSkewedClock crea un reloj desfasado offset respecto al del sistema y que deriva a razón de drift
+// partes por millón.
+func NewSkewedClock(offset time.Duration, drift float64) *SkewedClock {
+	return &SkewedClock{offset: offset, drift: drift, start: time.Now()}
+}
+
+// Now implementa Clock.
+func (c *SkewedClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	return now.Add(c.offset + c.driftSince(now))
+}
+
+// Monotonic implementa Clock. El intervalo medido también sufre la deriva del reloj.
+func (c *SkewedClock) Monotonic() time.Duration {
+	elapsed := time.Since(c.start)
+	return elapsed + time.Duration(c.drift*1e-6*float64(elapsed))
+}
+
+// driftSince calcula lo que ha derivado el reloj hasta el instante now. Requiere el mutex bloqueado.
+func (c *SkewedClock) driftSince(now time.Time) time.Duration {
+	return time.Duration(c.drift * 1e-6 * float64(now.Sub(c.start)))
+}
+
+// ManualClock es un reloj que sólo avanza cuando se llama a Advance, para pruebas deterministas.
+type ManualClock struct {
+	mu      sync.Mutex
+	now     time.Time     // Hora actual del reloj
+	elapsed time.Duration // Tiempo avanzado desde la creación, la lectura monotónica
+}
+
+// NewManualClock crea un reloj manual parado en la hora indicada.
+func NewManualClock(now time.Time) *ManualClock {
+	return &ManualClock{now: now}
+}
+
+// Now implementa Clock.
+func (c *ManualClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// Monotonic implementa Clock.
+func (c *ManualClock) Monotonic() time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.elapsed
+}
+
+// Advance hace avanzar el reloj, tanto su hora como su lectura monotónica.
+func (c *ManualClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	c.elapsed += d
+}
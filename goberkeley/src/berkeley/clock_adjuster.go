@@ -0,0 +1,81 @@
+package berkeley
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+// ClockAdjuster aplica las correcciones de un seguidor sobre el reloj real de la máquina.
+// Cuando un seguidor tiene uno configurado, sus correcciones de paso y graduales se entregan al
+// ClockAdjuster en lugar de aplicarse sobre su reloj corregido (VirtualClock).
+type ClockAdjuster interface {
+	// CheckCapability comprueba que el proceso puede modificar el reloj. Devuelve ErrClockPermission
+	// si le falta el permiso CAP_SYS_TIME y ErrClockUnsupported si la plataforma no lo permite.
+	CheckCapability() error
+	// Step aplica la corrección de golpe.
+	Step(delta time.Duration) error
+	// Slew reparte la corrección en el tiempo a la velocidad máxima que permite el sistema.
+	Slew(delta time.Duration) error
+	// ChangesSystemClock indica si las correcciones modifican de verdad el reloj de la máquina. Si no lo
+	// hacen, el seguidor las aplica además a su reloj corregido.
+	ChangesSystemClock() bool
+}
+
+// Modos del reloj del sistema que se pueden seleccionar desde la configuración.
+const (
+	SystemClockOff    = ""        // Las correcciones sólo se aplican al reloj corregido del seguidor
+	SystemClockDryRun = "dry_run" // Las correcciones se registran pero no se aplican
+	SystemClockOn     = "system"  // Las correcciones modifican el reloj del sistema
+)
+
+// ErrClockPermission indica que el proceso no tiene el permiso CAP_SYS_TIME para modificar el reloj.
+var ErrClockPermission = errors.New("el proceso no tiene permiso para modificar el reloj del sistema (CAP_SYS_TIME)")
+
+// ErrClockUnsupported indica que la plataforma no permite modificar el reloj del sistema.
+var ErrClockUnsupported = errors.New("la modificación del reloj del sistema no está soportada en esta plataforma")
+
+// NewClockAdjuster crea el ClockAdjuster del modo indicado y comprueba que se puede usar.
+// Con SystemClockOff devuelve nil: el seguidor sigue aplicando las correcciones a su reloj corregido.
+func NewClockAdjuster(mode string) (ClockAdjuster, error) {
+	switch mode {
+	case SystemClockOff:
+		return nil, nil
+	case SystemClockDryRun:
+		return &DryRunAdjuster{}, nil
+	case SystemClockOn:
+		adjuster := NewSystemClockAdjuster()
+		if err := adjuster.CheckCapability(); err != nil {
+			return nil, err
+		}
+		return adjuster, nil
+	default:
+		return nil, fmt.Errorf("modo de reloj del sistema desconocido: %s", mode)
+	}
+}
+
+// DryRunAdjuster registra las correcciones que se aplicarían al reloj del sistema sin aplicarlas.
+type DryRunAdjuster struct{}
+
+// CheckCapability implementa ClockAdjuster. En modo de prueba no hace falta ningún permiso.
+func (*DryRunAdjuster) CheckCapability() error {
+	return nil
+}
+
+// Step implementa ClockAdjuster.
+func (*DryRunAdjuster) Step(delta time.Duration) error {
+	log.Printf("[dry-run] Se aplicaría al reloj del sistema una corrección de paso de %v", delta)
+	return nil
+}
+
+// Slew implementa ClockAdjuster.
+func (*DryRunAdjuster) Slew(delta time.Duration) error {
+	log.Printf("[dry-run] Se aplicaría al reloj del sistema una corrección gradual de %v", delta)
+	return nil
+}
+
+// ChangesSystemClock implementa ClockAdjuster. En modo de prueba el reloj del sistema no cambia.
+func (*DryRunAdjuster) ChangesSystemClock() bool {
+	return false
+}
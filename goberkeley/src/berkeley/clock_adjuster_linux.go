@@ -0,0 +1,103 @@
+//go:build linux && (amd64 || arm64)
+
+package berkeley
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// Constantes de adjtimex(2) y capabilities(7) que no exporta golang.org/x/sys/unix.
+const (
+	adjOffsetSingleshot = 0x8001 // ADJ_OFFSET_SINGLESHOT: corrección gradual al estilo de adjtime(3)
+	capSysTime          = 25     // CAP_SYS_TIME
+	maxSingleshotOffset = 2000 * time.Second
+)
+
+// linuxClockAdjuster modifica el reloj del sistema en Linux: clock_settime(2) para las correcciones de
+// paso y adjtimex(2) para las graduales, que el núcleo aplica a un máximo de 500 ppm.
+type linuxClockAdjuster struct{}
+
+// NewSystemClockAdjuster crea el ClockAdjuster que modifica el reloj del sistema.
+func NewSystemClockAdjuster() ClockAdjuster {
+	return linuxClockAdjuster{}
+}
+
+// CheckCapability implementa ClockAdjuster leyendo las capacidades efectivas del proceso.
+func (linuxClockAdjuster) CheckCapability() error {
+	file, err := os.Open("/proc/self/status")
+	if err != nil {
+		return fmt.Errorf("no se pudieron leer las capacidades del proceso: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "CapEff:") {
+			continue
+		}
+		caps, err := strconv.ParseUint(strings.TrimSpace(strings.TrimPrefix(line, "CapEff:")), 16, 64)
+		if err != nil {
+			return fmt.Errorf("capacidades del proceso no válidas: %w", err)
+		}
+		if caps&(1<<capSysTime) == 0 {
+			return ErrClockPermission
+		}
+		return nil
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("no se pudieron leer las capacidades del proceso: %w", err)
+	}
+	return errors.New("no se encontraron las capacidades efectivas del proceso")
+}
+
+// Step implementa ClockAdjuster con clock_settime(2) sobre CLOCK_REALTIME.
+func (linuxClockAdjuster) Step(delta time.Duration) error {
+	var now unix.Timespec
+	if err := unix.ClockGettime(unix.CLOCK_REALTIME, &now); err != nil {
+		return fmt.Errorf("error al leer el reloj del sistema: %w", err)
+	}
+	target := unix.NsecToTimespec(now.Nano() + int64(delta))
+	_, _, errno := unix.Syscall(unix.SYS_CLOCK_SETTIME, uintptr(unix.CLOCK_REALTIME), uintptr(unsafe.Pointer(&target)), 0)
+	if errno != 0 {
+		return clockError("clock_settime", errno)
+	}
+	return nil
+}
+
+// ChangesSystemClock implementa ClockAdjuster.
+func (linuxClockAdjuster) ChangesSystemClock() bool {
+	return true
+}
+
+// Slew implementa ClockAdjuster con adjtimex(2) en modo ADJ_OFFSET_SINGLESHOT.
+func (linuxClockAdjuster) Slew(delta time.Duration) error {
+	if delta > maxSingleshotOffset || delta < -maxSingleshotOffset {
+		return fmt.Errorf("corrección gradual de %v fuera del rango de adjtimex (±%v)", delta, maxSingleshotOffset)
+	}
+	timex := unix.Timex{
+		Modes:  adjOffsetSingleshot,
+		Offset: delta.Microseconds(),
+	}
+	if _, err := unix.Adjtimex(&timex); err != nil {
+		return clockError("adjtimex", err)
+	}
+	return nil
+}
+
+// clockError traduce la falta de permisos a ErrClockPermission.
+func clockError(operation string, err error) error {
+	if errors.Is(err, unix.EPERM) {
+		return fmt.Errorf("%s: %w", operation, ErrClockPermission)
+	}
+	return fmt.Errorf("error en %s: %w", operation, err)
+}
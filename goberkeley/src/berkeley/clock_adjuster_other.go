@@ -0,0 +1,36 @@
+//go:build !linux || !(amd64 || arm64)
+
+package berkeley
+
+import (
+	"time"
+)
+
+// unsupportedClockAdjuster se usa en las plataformas donde no se puede modificar el reloj del sistema.
+type unsupportedClockAdjuster struct{}
+
+// NewSystemClockAdjuster crea el ClockAdjuster que modifica el reloj del sistema. En esta plataforma
+// todas sus operaciones devuelven ErrClockUnsupported.
+func NewSystemClockAdjuster() ClockAdjuster {
+	return unsupportedClockAdjuster{}
+}
+
+// CheckCapability implementa ClockAdjuster.
+func (unsupportedClockAdjuster) CheckCapability() error {
+	return ErrClockUnsupported
+}
+
+// Step implementa ClockAdjuster.
+func (unsupportedClockAdjuster) Step(delta time.Duration) error {
+	return ErrClockUnsupported
+}
+
+// Slew implementa ClockAdjuster.
+func (unsupportedClockAdjuster) Slew(delta time.Duration) error {
+	return ErrClockUnsupported
+}
+
+// ChangesSystemClock implementa ClockAdjuster.
+func (unsupportedClockAdjuster) ChangesSystemClock() bool {
+	return true
+}
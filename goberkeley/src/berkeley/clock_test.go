@@ -0,0 +1,60 @@
+package berkeley
+
+import (
+	"testing"
+	"time"
+)
+
+func TestManualClockAdvance(t *testing.T) {
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	clock := NewManualClock(start)
+
+	if got := clock.Now(); !got.Equal(start) {
+		t.Fatalf("hora %v, se esperaba %v", got, start)
+	}
+	clock.Advance(1500 * time.Millisecond)
+	if got := clock.Now(); !got.Equal(start.Add(1500 * time.Millisecond)) {
+		t.Errorf("hora %v tras avanzar 1,5 s", got)
+	}
+	if got := clock.Monotonic(); got != 1500*time.Millisecond {
+		t.Errorf("lectura monotónica %v, se esperaba 1.5s", got)
+	}
+}
+
+func TestSkewedClock(t *testing.T) {
+	clock := NewSkewedClock(5*time.Second, 1e5)
+
+	// El desfase inicial se suma a la hora del sistema; la deriva acumulada es despreciable
+	before := time.Now()
+	now := clock.Now()
+	after := time.Now()
+	if now.Before(before.Add(5*time.Second)) || now.After(after.Add(5*time.Second+time.Second)) {
+		t.Errorf("hora %v fuera del desfase de 5 s respecto a %v", now, before)
+	}
+
+	// A 100000 ppm los intervalos medidos son un 10 % más largos que los reales
+	time.Sleep(10 * time.Millisecond)
+	elapsedBefore := time.Since(clock.start)
+	monotonic := clock.Monotonic()
+	elapsedAfter := time.Since(clock.start)
+	if low, high := elapsedBefore+elapsedBefore/10, elapsedAfter+elapsedAfter/10; monotonic < low || monotonic > high {
+		t.Errorf("lectura monotónica %v, se esperaba entre %v y %v", monotonic, low, high)
+	}
+}
+
+func TestVirtualClockOverSkewedClock(t *testing.T) {
+	base := NewSkewedClock(-2*time.Second, 0)
+	clock := NewVirtualClock(base)
+
+	// Las correcciones se aplican sobre el reloj base sin modificarlo
+	clock.Adjust(2 * time.Second)
+	before := time.Now()
+	now := clock.Now()
+	after := time.Now()
+	if now.Before(before) || now.After(after) {
+		t.Errorf("hora corregida %v, se esperaba entre %v y %v", now, before, after)
+	}
+	if got := base.Now(); !got.Before(time.Now().Add(-time.Second)) {
+		t.Errorf("el reloj base %v ha cambiado", got)
+	}
+}
@@ -8,19 +8,84 @@ import (
 )
 
 type LeaderConfig struct {
-	Name    string `json:"name"`
-	Address string `json:"address"`
+	Name     string `json:"name"`
+	Address  string `json:"address"`
+	Priority int    `json:"priority"` // Prioridad en la elección de líder
+	// BroadcastAddress es la dirección del canal de difusión de las rondas y correcciones (vacía lo desactiva).
+	// Con zmq no debe coincidir con el puerto de mensajes asíncronos de ningún nodo (su puerto + async_port_offset)
+	BroadcastAddress string `json:"broadcast_address"`
 }
 
 type FollowerConfig struct {
-	Name    string `json:"name"`
-	Address string `json:"address"`
+	Name     string `json:"name"`
+	Address  string `json:"address"`
+	Priority int    `json:"priority"` // Prioridad en la elección de líder
+	// ClockOffset y ClockDrift simulan un reloj desfasado (en milisegundos) y con deriva (en partes por millón)
+	ClockOffset int64   `json:"clock_offset"`
+	ClockDrift  float64 `json:"clock_drift"`
+}
+
+// SubLeaderConfig describe un sub-líder: un seguidor del líder principal que sincroniza a su propio grupo.
+type SubLeaderConfig struct {
+	Name      string           `json:"name"`
+	Address   string           `json:"address"`
+	Priority  int              `json:"priority"`  // Prioridad en la elección de líder
+	Followers []FollowerConfig `json:"followers"` // Miembros del grupo del sub-líder
+	// BroadcastAddress es la dirección del canal de difusión del sub-líder a su grupo (vacía lo desactiva)
+	BroadcastAddress string `json:"broadcast_address"`
 }
 
 type Config struct {
 	Leader    LeaderConfig     `json:"leader"`
 	Followers []FollowerConfig `json:"followers"`
 	Timeout   time.Duration    `json:"timeout"`
+	// Transport es el transporte de los mensajes entre nodos: zmq (por defecto), tcp o memory
+	Transport string `json:"transport"`
+	// SubLeaders son los sub-líderes de la sincronización jerárquica; cada uno sincroniza a su grupo
+	SubLeaders []SubLeaderConfig `json:"sub_leaders"`
+	// Algorithm es el algoritmo de sincronización: berkeley (por defecto), gossip o convergence
+	Algorithm string `json:"algorithm"`
+	// GossipFanout es el número de pares que consulta cada nodo en cada ronda del modo gossip
+	GossipFanout int `json:"gossip_fanout"`
+	// GossipGain es la fracción de la diferencia media que corrige cada nodo en cada ronda del modo gossip
+	GossipGain float64 `json:"gossip_gain"`
+	// ConvergenceBound es la máxima diferencia en milisegundos con la que se acepta la lectura de otro reloj
+	// en la convergencia interactiva (algoritmo o agregación convergence)
+	ConvergenceBound int64 `json:"convergence_bound"`
+	// OutlierThreshold es la separación máxima en milisegundos entre relojes para entrar en la media (0 la desactiva)
+	OutlierThreshold int64 `json:"outlier_threshold"`
+	// Aggregation es la estrategia de agregación del líder: mean, median, rtt_weighted, reference, convergence o marzullo
+	Aggregation string `json:"aggregation"`
+	// ReferenceNode es el nodo cuyo reloj se toma como referencia con la estrategia reference
+	ReferenceNode string `json:"reference_node"`
+	// Samples es el número de muestras de tiempo que el líder pide a cada seguidor por ronda
+	Samples int `json:"samples"`
+	// MaxRTT es el tiempo de ida y vuelta máximo en milisegundos para aceptar una muestra (0 lo desactiva)
+	MaxRTT int64 `json:"max_rtt"`
+	// SyncInterval es el intervalo en milisegundos entre rondas del modo periódico (0 ejecuta una única ronda)
+	SyncInterval time.Duration `json:"sync_interval"`
+	// DriftWindow es el número de rondas con las que el líder estima la deriva de cada seguidor (0 la desactiva)
+	DriftWindow int `json:"drift_window"`
+	// AdjustMode es la política de corrección de los seguidores: step o slew
+	AdjustMode string `json:"adjust_mode"`
+	// MaxSlewRate es la velocidad máxima de la corrección gradual en partes por millón
+	MaxSlewRate float64 `json:"max_slew_rate"`
+	// StepLimit es la mayor corrección positiva en milisegundos que se aplica de golpe en modo slew
+	StepLimit int64 `json:"step_limit"`
+	// SystemClock indica si los seguidores modifican el reloj de la máquina: vacío (no), dry_run o system
+	SystemClock string `json:"system_clock"`
+	// ElectionTimeout es el silencio del líder en milisegundos tras el que los seguidores eligen otro (0 lo desactiva).
+	// En modo periódico debe superar SyncInterval más Timeout.
+	ElectionTimeout time.Duration `json:"election_timeout"`
+	// ProbeTimeout es la espera en milisegundos de cada sonda GET_TIME por UDP (0 pide el tiempo por el transporte)
+	ProbeTimeout int64 `json:"probe_timeout"`
+	// Retries es el número de intentos de cada petición síncrona sin respuesta (0 usa la política por defecto)
+	Retries int `json:"retries"`
+	// RetryBackoff es la espera en milisegundos antes del primer reintento, que se duplica en los siguientes
+	RetryBackoff int64 `json:"retry_backoff"`
+	// AsyncPortOffset es la distancia entre el puerto de cada nodo y el de sus mensajes asíncronos con zmq
+	// (0 usa DefaultAsyncPortOffset)
+	AsyncPortOffset int `json:"async_port_offset"`
 }
 
 func LoadConfig(filepath string) *Config {
@@ -40,12 +105,92 @@ func LoadConfig(filepath string) *Config {
 		return nil
 	}
 
+	// Un líder vivo sólo contacta con sus seguidores en cada ronda: si el silencio tolerado no cubre el
+	// intervalo entre rondas y su duración, los seguidores eligen otro líder entre dos rondas
+	if config.ElectionTimeout > 0 && config.SyncInterval > 0 && config.ElectionTimeout <= config.SyncInterval+config.Timeout {
+		fmt.Printf("Configuración no válida: election_timeout (%d ms) debe superar sync_interval más timeout (%d ms)\n", config.ElectionTimeout, config.SyncInterval+config.Timeout)
+		return nil
+	}
+
+	// Con zmq cada nodo recibe los mensajes asíncronos en su puerto desplazado, que no puede estar ocupado
+	if config.Transport == "" || config.Transport == ZMQTransportName {
+		if address, taken := config.asyncPortCollision(); taken != "" {
+			fmt.Printf("Configuración no válida: el puerto asíncrono de %s coincide con %s; cambie async_port_offset\n", address, taken)
+			return nil
+		}
+	}
+
 	// Imprimir la configuración cargada
 	fmt.Printf("Líder: %s (%s)\n", config.Leader.Name, config.Leader.Address)
+	if config.Leader.BroadcastAddress != "" {
+		fmt.Printf("Canal de difusión del líder: %s\n", config.Leader.BroadcastAddress)
+	}
 	for _, follower := range config.Followers {
 		fmt.Printf("Seguidor: %s (%s)\n", follower.Name, follower.Address)
 	}
+	for _, subLeader := range config.SubLeaders {
+		fmt.Printf("Sub-líder: %s (%s)\n", subLeader.Name, subLeader.Address)
+		for _, follower := range subLeader.Followers {
+			fmt.Printf("  Seguidor de %s: %s (%s)\n", subLeader.Name, follower.Name, follower.Address)
+		}
+	}
 	fmt.Printf("Timeout: %d ms\n", config.Timeout)
+	if config.Transport != "" {
+		fmt.Printf("Transporte: %s\n", config.Transport)
+	}
+	if config.Algorithm == GossipAlgorithm {
+		fmt.Printf("Algoritmo: gossip (%d pares por ronda, ganancia %.2f)\n", config.GossipFanout, config.GossipGain)
+	}
+	if config.Algorithm == InteractiveConvergenceAlgorithm {
+		fmt.Printf("Algoritmo: convergencia interactiva (cota %d ms)\n", config.ConvergenceBound)
+	}
+	fmt.Printf("Umbral de descarte: %d ms\n", config.OutlierThreshold)
+	fmt.Printf("Agregación: %s\n", config.Aggregation)
+	fmt.Printf("Muestras por seguidor: %d, RTT máximo: %d ms\n", config.Samples, config.MaxRTT)
+	fmt.Printf("Intervalo de sincronización: %d ms, ventana de deriva: %d rondas\n", config.SyncInterval, config.DriftWindow)
+	fmt.Printf("Tiempo de espera de elección: %d ms\n", config.ElectionTimeout)
+	fmt.Printf("Corrección de los seguidores: %s (máx. %.0f ppm, paso máx. %d ms)\n", config.AdjustMode, config.MaxSlewRate, config.StepLimit)
+	if config.ProbeTimeout > 0 {
+		fmt.Printf("Sondas de tiempo por UDP: espera %d ms\n", config.ProbeTimeout)
+	}
+	fmt.Printf("Reintentos: %d (espera inicial %d ms)\n", config.Retries, config.RetryBackoff)
+	if config.AsyncPortOffset != 0 {
+		fmt.Printf("Distancia al puerto asíncrono: %d\n", config.AsyncPortOffset)
+	}
 
 	return &config
 }
+
+// asyncPortCollision comprueba que el puerto de mensajes asíncronos de ningún nodo coincide con la
+// dirección de otro nodo o canal de difusión. Devuelve el nodo y la dirección que coinciden, o dos
+// cadenas vacías si no hay ninguna coincidencia.
+func (config *Config) asyncPortCollision() (string, string) {
+	offset := config.AsyncPortOffset
+	if offset == 0 {
+		offset = DefaultAsyncPortOffset
+	}
+
+	nodes := []string{config.Leader.Address}
+	taken := map[string]bool{config.Leader.Address: true, config.Leader.BroadcastAddress: true}
+	for _, follower := range config.Followers {
+		nodes = append(nodes, follower.Address)
+		taken[follower.Address] = true
+	}
+	for _, subLeader := range config.SubLeaders {
+		nodes = append(nodes, subLeader.Address)
+		taken[subLeader.Address] = true
+		taken[subLeader.BroadcastAddress] = true
+		for _, follower := range subLeader.Followers {
+			nodes = append(nodes, follower.Address)
+			taken[follower.Address] = true
+		}
+	}
+
+	for _, address := range nodes {
+		async, err := shiftPort(address, offset)
+		if err == nil && taken[async] {
+			return address, async
+		}
+	}
+	return "", ""
+}
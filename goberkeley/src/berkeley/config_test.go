@@ -0,0 +1,51 @@
+package berkeley
+
+import "testing"
+
+func TestAsyncPortCollision(t *testing.T) {
+	tests := []struct {
+		name   string
+		config Config
+		taken  string
+	}{
+		{
+			name: "sin coincidencias",
+			config: Config{
+				Leader:    LeaderConfig{Address: "127.0.0.1:8080", BroadcastAddress: "127.0.0.1:7080"},
+				Followers: []FollowerConfig{{Address: "127.0.0.1:8081"}},
+			},
+		},
+		{
+			name: "coincide con el canal de difusión",
+			config: Config{
+				Leader:    LeaderConfig{Address: "127.0.0.1:8080", BroadcastAddress: "127.0.0.1:9080"},
+				Followers: []FollowerConfig{{Address: "127.0.0.1:8081"}},
+			},
+			taken: "127.0.0.1:9080",
+		},
+		{
+			name: "coincide con otro nodo",
+			config: Config{
+				Leader:          LeaderConfig{Address: "127.0.0.1:8080"},
+				Followers:       []FollowerConfig{{Address: "127.0.0.1:8081"}},
+				AsyncPortOffset: 1,
+			},
+			taken: "127.0.0.1:8081",
+		},
+		{
+			name: "coincide con un miembro de un sub-líder",
+			config: Config{
+				Leader:     LeaderConfig{Address: "127.0.0.1:8080"},
+				SubLeaders: []SubLeaderConfig{{Address: "127.0.0.1:8090", Followers: []FollowerConfig{{Address: "127.0.0.1:9090"}}}},
+			},
+			taken: "127.0.0.1:9090",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, taken := tt.config.asyncPortCollision(); taken != tt.taken {
+				t.Errorf("dirección ocupada %q, se esperaba %q", taken, tt.taken)
+			}
+		})
+	}
+}
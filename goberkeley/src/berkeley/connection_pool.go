@@ -0,0 +1,144 @@
+//go:build !nozmq
+
+package berkeley
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	zmq "github.com/pebbe/zmq4"
+)
+
+// connectionPool mantiene abierto un socket REQ por cada dirección con la que habla un nodo, de modo
+// que las fases y rondas sucesivas reutilizan la misma conexión TCP en lugar de abrir una por mensaje.
+// Un socket REQ que no recibe respuesta a tiempo queda esperándola y no admite otro envío; por eso,
+// ante cualquier error en un intercambio, el socket se cierra y se descarta, y el siguiente mensaje a
+// esa dirección abre uno nuevo.
+type connectionPool struct {
+	mu          sync.Mutex
+	context     *zmq.Context
+	timeout     time.Duration // Tiempo máximo de espera de cada respuesta
+	connections map[string]*pooledConnection
+	closed      bool
+}
+
+// pooledConnection es el socket REQ abierto hacia una dirección. Un socket de ZeroMQ no se puede usar
+// desde varias goroutines a la vez, así que cada intercambio lo hace con el mutex bloqueado.
+type pooledConnection struct {
+	mu     sync.Mutex
+	socket *zmq.Socket
+}
+
+// errPoolClosed se devuelve al enviar por un pool que ya se cerró.
+var errPoolClosed = errors.New("el pool de conexiones está cerrado")
+
+// newConnectionPool crea un pool vacío sobre el contexto indicado.
+func newConnectionPool(context *zmq.Context, timeout time.Duration) *connectionPool {
+	return &connectionPool{
+		context:     context,
+		timeout:     timeout,
+		connections: make(map[string]*pooledConnection),
+	}
+}
+
+// connection devuelve la conexión de una dirección, creándola vacía si no existía.
+func (p *connectionPool) connection(address string) (*pooledConnection, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return nil, errPoolClosed
+	}
+	conn, ok := p.connections[address]
+	if !ok {
+		conn = &pooledConnection{}
+		p.connections[address] = conn
+	}
+	return conn, nil
+}
+
+// request envía un mensaje a la dirección y espera su respuesta por el socket abierto hacia ella,
+// abriéndolo si hace falta. Si el intercambio falla el socket se descarta.
+func (p *connectionPool) request(address, message string) (string, error) {
+	conn, err := p.connection(address)
+	if err != nil {
+		return "", err
+	}
+
+	conn.mu.Lock()
+	defer conn.mu.Unlock()
+
+	if conn.socket == nil {
+		socket, err := p.open(address)
+		if err != nil {
+			return "", err
+		}
+		conn.socket = socket
+	}
+
+	if _, err := conn.socket.Send(message, 0); err != nil {
+		conn.reset()
+		return "", errors.New("error al enviar el mensaje")
+	}
+	reply, err := conn.socket.Recv(0)
+	if err != nil {
+		// El socket REQ se queda esperando una respuesta que no llegará: hay que sustituirlo
+		conn.reset()
+		return "", errors.New("no se recibió respuesta del socket")
+	}
+	return reply, nil
+}
+
+// open crea y conecta un socket REQ hacia la dirección.
+func (p *connectionPool) open(address string) (*zmq.Socket, error) {
+	socket, err := p.context.NewSocket(zmq.REQ)
+	if err != nil {
+		return nil, errors.New("error al crear el socket REQ")
+	}
+	// Al cerrarlo no esperar a entregar los mensajes pendientes de un destino que no responde
+	socket.SetLinger(0)
+	socket.SetRcvtimeo(p.timeout)
+	socket.SetSndtimeo(p.timeout)
+	if err := socket.Connect("tcp://" + address); err != nil {
+		socket.Close()
+		return nil, errors.New("error al conectar con " + address)
+	}
+	return socket, nil
+}
+
+// reset cierra y descarta el socket de la conexión. Requiere el mutex de la conexión bloqueado.
+func (c *pooledConnection) reset() {
+	if c.socket != nil {
+		c.socket.Close()
+		c.socket = nil
+	}
+}
+
+// drop cierra la conexión con una dirección, si la había.
+func (p *connectionPool) drop(address string) {
+	p.mu.Lock()
+	conn, ok := p.connections[address]
+	delete(p.connections, address)
+	p.mu.Unlock()
+
+	if ok {
+		conn.mu.Lock()
+		conn.reset()
+		conn.mu.Unlock()
+	}
+}
+
+// close cierra todas las conexiones del pool; los envíos posteriores fallan con errPoolClosed.
+func (p *connectionPool) close() {
+	p.mu.Lock()
+	connections := p.connections
+	p.connections = make(map[string]*pooledConnection)
+	p.closed = true
+	p.mu.Unlock()
+
+	for _, conn := range connections {
+		conn.mu.Lock()
+		conn.reset()
+		conn.mu.Unlock()
+	}
+}
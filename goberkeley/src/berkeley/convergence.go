@@ -0,0 +1,134 @@
+package berkeley
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+)
+
+// InteractiveConvergenceAlgorithm sincroniza el grupo con el algoritmo de convergencia interactiva de
+// Lamport y Melliar-Smith, que tolera relojes defectuosos o maliciosos.
+const InteractiveConvergenceAlgorithm = "convergence"
+
+// convergenceTarget calcula la diferencia objetivo de la convergencia interactiva: la media de las
+// diferencias en la que las que superan bound en valor absoluto se sustituyen por la del propio nodo,
+// que es cero. offsets debe incluir al propio nodo. Devuelve la media y los nodos cuya lectura se
+// sustituyó.
+//
+// Con n nodos de los que como mucho f son defectuosos y n >= 3f+1, una lectura maliciosa sólo puede
+// desplazar la media en bound/n, de modo que los relojes correctos siguen convergiendo.
+func convergenceTarget(offsets map[string]int64, bound int64) (int64, []string) {
+	if len(offsets) == 0 {
+		return 0, nil
+	}
+	var sum int64
+	var replaced []string
+	for name, offset := range offsets {
+		if offset > bound || offset < -bound {
+			replaced = append(replaced, name)
+			continue
+		}
+		sum += offset
+	}
+	sort.Strings(replaced)
+	return sum / int64(len(offsets)), replaced
+}
+
+// MaxByzantineFaults devuelve el número máximo de relojes defectuosos o maliciosos que tolera la
+// convergencia interactiva con n nodos: el mayor f con n >= 3f+1.
+func MaxByzantineFaults(n int) int {
+	if n < 1 {
+		return 0
+	}
+	return (n - 1) / 3
+}
+
+// ConvergenceAggregator aplica la convergencia interactiva en el líder: las diferencias que superan
+// Bound se sustituyen por la del propio líder (cero) antes de hacer la media, de modo que un seguidor
+// que miente en su respuesta no puede arrastrar al resto más allá de Bound/n.
+type ConvergenceAggregator struct {
+	Bound time.Duration // Máxima diferencia que se acepta como lectura de un reloj correcto
+}
+
+// Aggregate implementa Aggregator. La muestra del líder, con diferencia cero, es la lectura propia.
+func (a ConvergenceAggregator) Aggregate(samples map[string]*FollowerInfo) (int64, error) {
+	if len(samples) == 0 {
+		return 0, errNoSamples
+	}
+	offsets := make(map[string]int64, len(samples))
+	for name, sample := range samples {
+		offsets[name] = sample.DiffTime
+	}
+	target, replaced := convergenceTarget(offsets, int64(a.Bound))
+	for _, name := range replaced {
+		log.Printf("Lectura de %s sustituida por la del líder: diferencia %v mayor que la cota de %v", name, time.Duration(offsets[name]), a.Bound)
+	}
+	return target, nil
+}
+
+// ConvergenceNode es un nodo del algoritmo de convergencia interactiva. No hay líder: en cada ronda
+// todos los nodos leen el reloj de todos los demás, sustituyen por su propio reloj las lecturas que se
+// alejan más de Bound y se corrigen con la media. Con n nodos tolera hasta f = (n-1)/3 relojes
+// defectuosos o maliciosos.
+type ConvergenceNode struct {
+	*peerNode
+
+	// Bound es la máxima diferencia con la que se acepta la lectura de otro nodo. Debe superar la
+	// diferencia entre relojes correctos más el error de lectura.
+	Bound time.Duration
+}
+
+// NewConvergenceNode crea un nodo de convergencia interactiva que escucha en address y lee el reloj de
+// los nodos indicados (nombre -> dirección). Las correcciones se aplican sobre un VirtualClock
+// construido encima del reloj indicado, o sobre el propio reloj si ya es un VirtualClock.
+func NewConvergenceNode(name, address string, timeout time.Duration, peers map[string]string, clock Clock) (*ConvergenceNode, error) {
+	base, err := newPeerNode("de convergencia", name, address, timeout, peers, clock)
+	if err != nil {
+		return nil, err
+	}
+	return &ConvergenceNode{peerNode: base}, nil
+}
+
+// RunPeriodic ejecuta una ronda de convergencia cada intervalo (interval) hasta que se cancela el contexto.
+func (c *ConvergenceNode) RunPeriodic(ctx context.Context, interval time.Duration) error {
+	if c.Bound <= 0 {
+		return fmt.Errorf("cota de lectura no válida para la convergencia interactiva: %v", c.Bound)
+	}
+	n := len(c.peers()) + 1
+	log.Printf("Convergencia interactiva de %s: %d nodos, tolera %d relojes defectuosos", c.aAbstractNode.Name, n, MaxByzantineFaults(n))
+	if MaxByzantineFaults(n) == 0 {
+		log.Printf("Con %d nodos la convergencia interactiva no tolera ningún reloj defectuoso: hacen falta al menos 4", n)
+	}
+	return c.runPeriodic(ctx, interval, c.RunRound)
+}
+
+// RunRound ejecuta una ronda de convergencia interactiva: lee el reloj de todos los demás nodos,
+// sustituye por el propio las lecturas que superan Bound y aplica la media como corrección. Un nodo que
+// no responde cuenta como una lectura sustituida. Devuelve la corrección aplicada en nanosegundos.
+func (c *ConvergenceNode) RunRound() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.Rounds++
+	self := c.aAbstractNode.Name
+	offsets := map[string]int64{self: 0}
+	for _, peer := range c.samplePeers(c.peers()) {
+		if peer.State != Responded {
+			// Sin lectura se usa el propio reloj, igual que con una lectura fuera de la cota
+			log.Printf("Nodo %s sin lectura válida en la ronda %d de %s (%s): se usa el reloj propio", peer.Name, c.Rounds, self, peer.State)
+			offsets[peer.Name] = 0
+			continue
+		}
+		offsets[peer.Name] = peer.DiffTime
+	}
+
+	correction, replaced := convergenceTarget(offsets, int64(c.Bound))
+	for _, name := range replaced {
+		log.Printf("Lectura de %s sustituida por el reloj de %s: diferencia %v mayor que la cota de %v", name, self, time.Duration(offsets[name]), c.Bound)
+	}
+	c.adjustClock(correction)
+	log.Printf("Ronda %d de %s: %d lecturas, %d sustituidas, corrección %v", c.Rounds, self, len(offsets)-1, len(replaced), time.Duration(correction))
+	return correction
+}
@@ -0,0 +1,33 @@
+package berkeley
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestConvergenceTarget(t *testing.T) {
+	tests := []struct {
+		name     string
+		offsets  map[string]int64
+		bound    int64
+		target   int64
+		replaced []string
+	}{
+		{name: "sin nodos", offsets: map[string]int64{}, bound: 20},
+		{name: "todas dentro de la cota", offsets: map[string]int64{"a": 0, "b": 10, "c": -4}, bound: 20, target: 2},
+		{name: "una por encima", offsets: map[string]int64{"a": 0, "b": 10, "c": 1000}, bound: 20, target: 3, replaced: []string{"c"}},
+		{name: "una por debajo", offsets: map[string]int64{"a": 0, "b": -30, "c": 6}, bound: 20, target: 2, replaced: []string{"b"}},
+		{name: "en la cota se acepta", offsets: map[string]int64{"a": 0, "b": 20, "c": -20, "d": 40}, bound: 20, target: 0, replaced: []string{"d"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target, replaced := convergenceTarget(tt.offsets, tt.bound)
+			if target != tt.target {
+				t.Errorf("objetivo %d, se esperaba %d", target, tt.target)
+			}
+			if !reflect.DeepEqual(replaced, tt.replaced) {
+				t.Errorf("sustituidos %v, se esperaba %v", replaced, tt.replaced)
+			}
+		})
+	}
+}
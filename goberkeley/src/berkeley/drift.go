@@ -0,0 +1,97 @@
+package berkeley
+
+import (
+	"log"
+)
+
+// driftSample es una medida de la diferencia libre de un seguidor respecto al líder en una ronda:
+// la diferencia observada sin las correcciones de paso aplicadas hasta entonces a ambos relojes.
+type driftSample struct {
+	at     int64 // Hora del líder (T1) en la que se tomó la muestra, en nanosegundos
+	offset int64 // Diferencia libre del seguidor respecto al líder, en nanosegundos
+}
+
+// recordDriftSamples guarda la diferencia libre de cada seguidor que respondió en la ronda y, cuando un
+// seguidor acumula DriftWindow muestras, estima su deriva con una regresión lineal y calcula la
+// corrección de frecuencia que se le enviará junto a la de tiempo. La deriva y la corrección quedan en
+// el FollowerInfo del seguidor (Drift y RateCorrection, en partes por millón).
+// Con DriftWindow menor que 2 la estimación está desactivada.
+func (l *Leader) recordDriftSamples() {
+	if l.DriftWindow < 2 {
+		return
+	}
+	if l.driftHistory == nil {
+		l.driftHistory = make(map[string][]driftSample)
+	}
+	if l.appliedCorrections == nil {
+		l.appliedCorrections = make(map[string]int64)
+	}
+
+	for name, follower := range l.SuccessfulFollowers {
+		// Quitar las correcciones de paso acumuladas: DiffTime = (seguidor + sus pasos) - (líder + sus pasos).
+		// Si el seguidor informa de las que tiene en vigor se usan ésas, porque una corrección gradual
+		// se aplica a lo largo de varias rondas y puede sustituirse antes de completarse
+		applied := l.appliedCorrections[name]
+		if follower.Corrected != nil {
+			applied = *follower.Corrected
+		}
+		offset := follower.DiffTime - applied + int64(l.ClockOffset)
+		history := append(l.driftHistory[name], driftSample{at: follower.CurrentTime, offset: offset})
+		if len(history) > l.DriftWindow {
+			history = history[len(history)-l.DriftWindow:]
+		}
+		l.driftHistory[name] = history
+
+		if len(history) < l.DriftWindow {
+			continue
+		}
+		slope, ok := linearRegressionSlope(history)
+		if !ok {
+			continue
+		}
+		// La pendiente está en nanosegundos por nanosegundo; se expresa en partes por millón
+		follower.Drift = slope * 1e6
+		follower.RateCorrection = -follower.Drift
+		log.Printf("Deriva estimada del seguidor %s: %.3f ppm con %d rondas, corrección de frecuencia %.3f ppm", name, follower.Drift, len(history), follower.RateCorrection)
+	}
+}
+
+// registerAppliedCorrection anota la corrección de paso que un seguidor aplicó con éxito, para los
+// seguidores que no informan de la corrección en vigor. Si además aplicó una corrección de frecuencia,
+// su historial se reinicia para medir sólo la deriva residual.
+func (l *Leader) registerAppliedCorrection(follower *FollowerInfo) {
+	if l.DriftWindow < 2 {
+		return
+	}
+	l.appliedCorrections[follower.Name] += follower.Delta
+	if follower.RateCorrection != 0 {
+		delete(l.driftHistory, follower.Name)
+	}
+}
+
+// linearRegressionSlope calcula por mínimos cuadrados la pendiente de la diferencia frente al tiempo.
+// Retorna false si las muestras no permiten calcularla (todas tomadas en el mismo instante).
+func linearRegressionSlope(samples []driftSample) (float64, bool) {
+	n := float64(len(samples))
+	if n < 2 {
+		return 0, false
+	}
+
+	// Tomar la primera muestra como origen para no perder precisión con horas de la época UNIX
+	origin := samples[0]
+	var sumX, sumY, sumXY, sumXX float64
+	for _, sample := range samples {
+		x := float64(sample.at - origin.at)
+		y := float64(sample.offset - origin.offset)
+		sumX += x
+		sumY += y
+		sumXY += x * y
+		sumXX += x * x
+	}
+
+	denominator := n*sumXX - sumX*sumX
+	if denominator == 0 {
+		return 0, false
+	}
+	return (n*sumXY - sumX*sumY) / denominator, true
+}
@@ -0,0 +1,32 @@
+package berkeley
+
+import (
+	"math"
+	"testing"
+)
+
+func TestLinearRegressionSlope(t *testing.T) {
+	tests := []struct {
+		name    string
+		samples []driftSample
+		slope   float64
+		ok      bool
+	}{
+		{name: "una muestra", samples: []driftSample{{at: 0, offset: 5}}},
+		{name: "mismo instante", samples: []driftSample{{at: 10, offset: 1}, {at: 10, offset: 2}}},
+		{name: "sin deriva", samples: []driftSample{{at: 0, offset: 7}, {at: 1000, offset: 7}, {at: 2000, offset: 7}}, ok: true},
+		{name: "adelanta 50 ppm", samples: []driftSample{{at: 0, offset: 0}, {at: 1000000, offset: 50}, {at: 2000000, offset: 100}}, slope: 50e-6, ok: true},
+		{name: "atrasa con ruido", samples: []driftSample{{at: 0, offset: 1}, {at: 1000, offset: -2}, {at: 2000, offset: -3}, {at: 3000, offset: -6}}, slope: -0.0022, ok: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			slope, ok := linearRegressionSlope(tt.samples)
+			if ok != tt.ok {
+				t.Fatalf("ok = %v, se esperaba %v", ok, tt.ok)
+			}
+			if math.Abs(slope-tt.slope) > 1e-12 {
+				t.Errorf("pendiente %g, se esperaba %g", slope, tt.slope)
+			}
+		})
+	}
+}
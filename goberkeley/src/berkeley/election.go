@@ -0,0 +1,339 @@
+package berkeley
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// Peer describe a otro miembro del grupo para la elección de líder.
+type Peer struct {
+	Name     string // Nombre del nodo
+	Address  string // Dirección del nodo en formato "host:puerto"
+	Priority int    // Prioridad del nodo: en la elección gana el de mayor prioridad
+}
+
+// electionRequest es el mensaje ELECTION que un candidato envía a los nodos de mayor prioridad.
+type electionRequest struct {
+	Message       string `json:"message"`
+	Operation     string `json:"operation"`
+	Candidate     string `json:"candidate"`
+	CandidateAddr string `json:"candidate_address"`
+	Priority      int    `json:"priority"`
+}
+
+// coordinatorRequest es el mensaje COORDINATOR con el que el ganador de la elección se anuncia como líder.
+type coordinatorRequest struct {
+	Message    string `json:"message"`
+	Operation  string `json:"operation"`
+	LeaderName string `json:"leader_name"`
+	LeaderAddr string `json:"leader_address"`
+	Priority   int    `json:"priority"`
+}
+
+// election guarda el estado de la elección de líder de un seguidor.
+type election struct {
+	mu          sync.Mutex
+	lastContact time.Duration      // Lectura monotónica del último mensaje recibido del líder
+	running     bool               // Hay una elección en curso iniciada por este seguidor
+	coordinator chan struct{}      // Se cierra al recibir un COORDINATOR durante la elección
+	stopped     bool               // El líder cerró el grupo: no se vigila más su silencio
+	promoted    *Leader            // Líder creado al ganar la elección
+	cancel      context.CancelFunc // Detiene el líder promovido
+}
+
+// outranks indica si el nodo (priority, name) gana al nodo (otherPriority, otherName) en la elección.
+// A igual prioridad gana el nombre mayor, para que el resultado sea siempre el mismo.
+func outranks(priority int, name string, otherPriority int, otherName string) bool {
+	if priority != otherPriority {
+		return priority > otherPriority
+	}
+	return name > otherName
+}
+
+// markLeaderContact anota que el líder acaba de comunicarse con el seguidor.
+func (f *Follower) markLeaderContact() {
+	f.election.mu.Lock()
+	defer f.election.mu.Unlock()
+	f.election.lastContact = f.clock.Monotonic()
+}
+
+// stopElectionWatchdog deja de vigilar el silencio del líder, por ejemplo tras recibir CLOSE.
+func (f *Follower) stopElectionWatchdog() {
+	f.election.mu.Lock()
+	defer f.election.mu.Unlock()
+	f.election.stopped = true
+}
+
+// watchLeader comprueba periódicamente si el líder lleva más de ElectionTimeout sin comunicarse y,
+// en ese caso, inicia una elección. Termina cuando el líder cierra el grupo o este seguidor pasa a líder.
+func (f *Follower) watchLeader() {
+	f.markLeaderContact()
+	ticker := time.NewTicker(f.ElectionTimeout / 2)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		f.election.mu.Lock()
+		stopped := f.election.stopped || f.election.promoted != nil
+		silent := f.clock.Monotonic()-f.election.lastContact > f.ElectionTimeout
+		running := f.election.running
+		leaderAddress := f.LeaderAddress
+		f.election.mu.Unlock()
+
+		if stopped {
+			log.Printf("Seguidor %s deja de vigilar al líder", f.aAbstractNode.Name)
+			return
+		}
+		if silent && !running {
+			log.Printf("⚠️ El líder %s lleva más de %v sin comunicarse con %s: se inicia una elección", leaderAddress, f.ElectionTimeout, f.aAbstractNode.Name)
+			go f.startElection()
+		}
+	}
+}
+
+// startElection ejecuta el algoritmo del matón (Bully): envía ELECTION a los nodos de mayor prioridad;
+// si ninguno responde, este seguidor gana, se anuncia con COORDINATOR y pasa a ser líder. Si alguno
+// responde, espera su COORDINATOR y, si no llega a tiempo, vuelve a empezar.
+func (f *Follower) startElection() {
+	f.election.mu.Lock()
+	if f.election.running || f.election.promoted != nil {
+		f.election.mu.Unlock()
+		return
+	}
+	f.election.running = true
+	f.election.mu.Unlock()
+
+	defer func() {
+		f.election.mu.Lock()
+		f.election.running = false
+		f.election.coordinator = nil
+		f.election.mu.Unlock()
+	}()
+
+	name := f.aAbstractNode.Name
+	request, err := json.Marshal(electionRequest{
+		Message:       "Elección de nuevo líder",
+		Operation:     "ELECTION",
+		Candidate:     name,
+		CandidateAddr: f.aAbstractNode.Address,
+		Priority:      f.Priority,
+	})
+	if err != nil {
+		log.Printf("Error al serializar el mensaje de elección: %v", err)
+		return
+	}
+
+	for {
+		coordinator := make(chan struct{})
+		f.election.mu.Lock()
+		f.election.coordinator = coordinator
+		f.election.mu.Unlock()
+
+		// Preguntar a los nodos de mayor prioridad si siguen vivos
+		answered := false
+		for _, peer := range f.Peers {
+			if !outranks(peer.Priority, peer.Name, f.Priority, name) {
+				continue
+			}
+			reply, err := f.aAbstractNode.SendMessageOnce(peer.Address, string(request))
+			if err != nil {
+				log.Printf("El nodo %s no responde a la elección: %v", peer.Name, err)
+				continue
+			}
+			var response map[string]string
+			if err := json.Unmarshal([]byte(reply), &response); err == nil && response["operation"] == "ELECTION_OK" {
+				log.Printf("El nodo %s, de mayor prioridad, toma el relevo de la elección", peer.Name)
+				answered = true
+			}
+		}
+
+		if !answered {
+			f.becomeLeader()
+			return
+		}
+
+		// Esperar el anuncio del nuevo líder; si no llega, repetir la elección
+		select {
+		case <-coordinator:
+			return
+		case <-time.After(f.ElectionTimeout):
+			log.Printf("No se recibió el anuncio del nuevo líder en %s: se repite la elección", name)
+		}
+	}
+}
+
+// becomeLeader anuncia a todos los miembros que este seguidor es el nuevo líder y crea un Leader con
+// las direcciones de los demás miembros, excepto la del líder anterior, que ejecuta rondas periódicas.
+func (f *Follower) becomeLeader() {
+	name := f.aAbstractNode.Name
+	address := f.aAbstractNode.Address
+	log.Printf("👑 El seguidor %s gana la elección y pasa a ser el líder", name)
+
+	f.election.mu.Lock()
+	oldLeader := f.LeaderAddress
+	f.LeaderAddress = address
+	f.election.mu.Unlock()
+
+	members := make(map[string]string)
+	for _, peer := range f.Peers {
+		if peer.Address != oldLeader {
+			members[peer.Name] = peer.Address
+		}
+	}
+	f.announceCoordinator(members)
+
+	// El nuevo líder comparte el reloj corregido del seguidor y los mensajes le llegan a través de él
+	leader, err := InitializeLeaderNodeWithClock(name, address, f.aAbstractNode.Timeout, members, f.clock)
+	if err != nil {
+		log.Printf("Error al crear el líder promovido %s: %v", name, err)
+		return
+	}
+	leader.Priority = f.Priority
+	leader.routed = true
+	if f.OnPromote != nil {
+		f.OnPromote(leader)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	f.election.mu.Lock()
+	f.election.promoted = leader
+	f.election.cancel = cancel
+	f.election.mu.Unlock()
+
+	interval := f.SyncInterval
+	if interval <= 0 {
+		interval = f.ElectionTimeout / 2
+	}
+	go func() {
+		if err := leader.RunPeriodic(ctx, interval); err != nil {
+			log.Printf("Error en el líder promovido %s: %v", name, err)
+		}
+	}()
+}
+
+// announceCoordinator envía COORDINATOR a los miembros indicados para que acepten al nuevo líder.
+func (f *Follower) announceCoordinator(members map[string]string) {
+	announceLeader(f.aAbstractNode, Peer{Name: f.aAbstractNode.Name, Address: f.aAbstractNode.Address, Priority: f.Priority}, members)
+}
+
+// announceLeader envía desde el nodo indicado un COORDINATOR que anuncia a leader como líder a los
+// miembros indicados.
+func announceLeader(node *AbstractNode, leader Peer, members map[string]string) {
+	request, err := json.Marshal(coordinatorRequest{
+		Message:    "Nuevo líder",
+		Operation:  "COORDINATOR",
+		LeaderName: leader.Name,
+		LeaderAddr: leader.Address,
+		Priority:   leader.Priority,
+	})
+	if err != nil {
+		log.Printf("Error al serializar el anuncio de líder: %v", err)
+		return
+	}
+	for name, address := range members {
+		if _, err := node.SendMessageOnce(address, string(request)); err != nil {
+			log.Printf("No se pudo anunciar el líder %s a %s: %v", leader.Name, name, err)
+		}
+	}
+}
+
+// checkLeader avisa al líder que envió una petición desde sender de cuál es el líder de este
+// seguidor, si no es él. Tras una elección el líder anterior puede volver y seguir ejecutando rondas:
+// con el COORDINATOR del líder actual, el de menor prioridad de los dos cede el puesto.
+func (f *Follower) checkLeader(sender string) {
+	f.election.mu.Lock()
+	current := f.LeaderAddress
+	promoted := f.election.promoted != nil
+	f.election.mu.Unlock()
+	if sender == current || promoted {
+		return
+	}
+	for _, peer := range f.Peers {
+		if peer.Address == current {
+			log.Printf("⚠️ %s ejecuta rondas pero el líder de %s es %s: se le anuncia", sender, f.aAbstractNode.Name, peer.Name)
+			go announceLeader(f.aAbstractNode, peer, map[string]string{sender: sender})
+			return
+		}
+	}
+}
+
+// handleElection responde a un ELECTION de un candidato de menor prioridad y toma el relevo de la
+// elección. Si este seguidor ya es líder, se vuelve a anunciar al candidato.
+func (f *Follower) handleElection(data map[string]interface{}) string {
+	candidate, _ := data["candidate"].(string)
+	candidateAddr, _ := data["candidate_address"].(string)
+	priority, _ := data["priority"].(float64)
+	name := f.aAbstractNode.Name
+	log.Printf("🗳️ Operación ELECTION: candidato %s (prioridad %d) en el seguidor %s", candidate, int(priority), name)
+
+	if !outranks(f.Priority, name, int(priority), candidate) {
+		return fmt.Sprintf(`{"followerName":"%s","operation":"ELECTION_IGNORED"}`, name)
+	}
+
+	if leader := f.PromotedLeader(); leader != nil {
+		go f.announceCoordinator(map[string]string{candidate: candidateAddr})
+	} else {
+		go f.startElection()
+	}
+	return fmt.Sprintf(`{"followerName":"%s","operation":"ELECTION_OK"}`, name)
+}
+
+// handleCoordinator acepta al nuevo líder anunciado y detiene la elección en curso, si la hay. Si este
+// seguidor ya es líder, sólo cede el puesto a un nodo de mayor prioridad; a los demás se les vuelve a
+// anunciar como líder.
+func (f *Follower) handleCoordinator(data map[string]interface{}) string {
+	leaderName, _ := data["leader_name"].(string)
+	leaderAddr, _ := data["leader_address"].(string)
+	priority, _ := data["priority"].(float64)
+	name := f.aAbstractNode.Name
+	log.Printf("👑 Operación COORDINATOR: %s (%s) es el nuevo líder de %s", leaderName, leaderAddr, name)
+
+	if leader := f.PromotedLeader(); leader != nil {
+		if outranks(f.Priority, name, int(priority), leaderName) {
+			go f.announceCoordinator(map[string]string{leaderName: leaderAddr})
+			return fmt.Sprintf(`{"followerName":"%s","operation":"COORDINATOR_IGNORED"}`, name)
+		}
+		f.stepDown()
+	}
+
+	f.election.mu.Lock()
+	f.LeaderAddress = leaderAddr
+	f.election.lastContact = f.clock.Monotonic()
+	if f.election.coordinator != nil {
+		close(f.election.coordinator)
+		f.election.coordinator = nil
+	}
+	f.election.mu.Unlock()
+
+	return fmt.Sprintf(`{"followerName":"%s","operation":"COORDINATOR_OK"}`, f.aAbstractNode.Name)
+}
+
+// stepDown detiene el líder promovido, que cede el puesto a otro de mayor prioridad, y el seguidor
+// vuelve a vigilar al líder.
+func (f *Follower) stepDown() {
+	f.election.mu.Lock()
+	leader, cancel := f.election.promoted, f.election.cancel
+	f.election.promoted, f.election.cancel = nil, nil
+	f.election.mu.Unlock()
+	if leader == nil {
+		return
+	}
+
+	log.Printf("El líder promovido %s cede el puesto y vuelve a ser seguidor", f.aAbstractNode.Name)
+	leader.resign()
+	cancel()
+	if f.ElectionTimeout > 0 {
+		go f.watchLeader()
+	}
+}
+
+// PromotedLeader devuelve el líder en el que se ha convertido este seguidor al ganar una elección,
+// o nil si sigue siendo seguidor.
+func (f *Follower) PromotedLeader() *Leader {
+	f.election.mu.Lock()
+	defer f.election.mu.Unlock()
+	return f.election.promoted
+}
@@ -5,13 +5,77 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"sync"
 	"time"
 )
 
+// AdjustMode indica cómo aplica el seguidor las correcciones de tiempo recibidas del líder.
+type AdjustMode string
+
+const (
+	// StepMode aplica cada corrección de golpe.
+	StepMode AdjustMode = "step"
+	// SlewMode reparte en el tiempo las correcciones negativas, para que el reloj nunca retroceda,
+	// y las positivas que superan StepLimit.
+	SlewMode AdjustMode = "slew"
+)
+
 // Follower representa un nodo seguidor en el sistema distribuido.
 type Follower struct {
 	aAbstractNode *AbstractNode
 	LeaderAddress string
+	clock         *VirtualClock // Reloj corregido con las actualizaciones recibidas del líder
+	// AdjustMode es la política de aplicación de las correcciones; vacía equivale a StepMode.
+	AdjustMode AdjustMode
+	// MaxSlewRate es la velocidad máxima de la corrección gradual en partes por millón (DefaultMaxSlewRate si es 0).
+	MaxSlewRate float64
+	// StepLimit es la mayor corrección positiva que se aplica de golpe en SlewMode.
+	StepLimit time.Duration
+	// Adjuster, si no es nil, recibe las correcciones de paso y graduales para aplicarlas al reloj de la
+	// máquina en lugar de al reloj corregido. Las correcciones de frecuencia siguen en el reloj corregido.
+	// Si el Adjuster no cambia el reloj de la máquina (ChangesSystemClock), también se aplican al corregido.
+	Adjuster ClockAdjuster
+	// UDPProbes indica si el seguidor atiende también por UDP, en su misma dirección, las peticiones
+	// GET_TIME del líder.
+	UDPProbes bool
+	// BroadcastAddress es la dirección del canal de difusión del líder a la que se suscribe el
+	// seguidor (vacía si el líder no difunde).
+	BroadcastAddress string
+	// Priority es la prioridad del seguidor en la elección de líder.
+	Priority int
+	// Peers son los demás miembros del grupo, líder actual incluido, que participan en la elección.
+	Peers []Peer
+	// ElectionTimeout es el silencio del líder tras el que se inicia una elección (0 la desactiva).
+	ElectionTimeout time.Duration
+	// SyncInterval es el intervalo entre rondas si este seguidor pasa a ser líder (ElectionTimeout/2 si es 0).
+	SyncInterval time.Duration
+	// OnPromote, si no es nil, permite configurar el líder creado al ganar una elección antes de arrancarlo.
+	OnPromote func(*Leader)
+
+	election election      // Estado de la elección de líder
+	updates  appliedUpdate // Última corrección aplicada, para reconocer los reintentos del líder
+}
+
+// appliedUpdate recuerda la última corrección aplicada y su respuesta. Si el líder no recibe la
+// respuesta a tiempo reenvía la misma corrección; el seguidor la reconoce por su identificador y
+// repite la respuesta sin volver a aplicarla.
+type appliedUpdate struct {
+	mu    sync.Mutex
+	id    string
+	reply string
+}
+
+// apply ejecuta la corrección identificada por id salvo que sea la última ya aplicada, en cuyo caso
+// devuelve la respuesta que se dio entonces. Las correcciones sin identificador se aplican siempre.
+func (u *appliedUpdate) apply(id string, update func() string) (string, bool) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if id != "" && id == u.id {
+		return u.reply, false
+	}
+	reply := update()
+	u.id, u.reply = id, reply
+	return reply, true
 }
 
 // InitializeNode inicializa el nodo seguidor con su información específica.
@@ -26,8 +90,15 @@ func InitializeFollowerNode(name, address, leaderAddress string, timeout time.Du
 	return f, nil
 }
 
-// NewFollower crea e inicializa un nuevo nodo seguidor.
+// NewFollower crea e inicializa un nuevo nodo seguidor sobre el reloj del sistema.
 func NewFollower(name, address, leaderAddress string, timeout time.Duration) (*Follower, error) {
+	return NewFollowerWithClock(name, address, leaderAddress, timeout, SystemClock{})
+}
+
+// NewFollowerWithClock crea e inicializa un nuevo nodo seguidor sobre el reloj indicado. Las
+// correcciones del líder se aplican sobre un VirtualClock construido encima de ese reloj, o sobre el
+// propio reloj si ya es un VirtualClock.
+func NewFollowerWithClock(name, address, leaderAddress string, timeout time.Duration, clock Clock) (*Follower, error) {
 	// Inicializar el nodo abstracto usando NewAbstractNode
 	abstractNode, err := NewAbstractNode(name, address, timeout)
 	if err != nil {
@@ -38,6 +109,7 @@ func NewFollower(name, address, leaderAddress string, timeout time.Duration) (*F
 	follower := &Follower{
 		aAbstractNode: abstractNode,
 		LeaderAddress: leaderAddress,
+		clock:         virtualClockFor(clock),
 	}
 	follower.aAbstractNode.Handler = follower
 	return follower, nil
@@ -63,72 +135,272 @@ func (f *Follower) HandleProcess(message string) (string, error) {
 
 	// log.Printf("Procesando mensaje del líder: %s desde %s con operación: %s", leaderAddr, f.LeaderAddress, operation)
 
+	// Cualquier mensaje de sincronización del líder demuestra que sigue vivo
+	if operation == "GET_TIME" || operation == "UPDATE_TIME" {
+		f.markLeaderContact()
+	}
+	// Un GET_TIME de otro líder indica que hay dos a la vez tras una elección
+	if sender, _ := data["leader_address"].(string); operation == "GET_TIME" && sender != "" {
+		f.checkLeader(sender)
+	}
+
 	// Procesar según la operación especificada
 	switch operation {
 	case "GET_TIME":
-		// Acceder a 'time' y asegurar su tipo como float64
-		T0Float, ok := data["time"].(float64)
-		if !ok {
-			log.Printf("Error al convertir data[\"time\"] a float64")
+		// Anotar la hora de recepción de la solicitud (T2) antes de cualquier otro procesamiento
+		T2 := f.getCurrentTime()
+		received := f.clock.Monotonic()
+		version := messageVersion(data)
+
+		// Leer la hora de envío del líder (T1) en la unidad de su versión
+		T1, err := wireInt64(data, "time")
+		if err != nil {
+			log.Printf("Error al leer data[\"time\"]: %v", err)
 			return "", errors.New("Error al procesar el campo Time")
 		}
-
-		// Convertir de float64 a int64
-		T0 := int64(T0Float)
-
-		//log.Printf("T0 recibido: %d", T0)
-		currentTime := f.getCurrentTime()
-
-		log.Printf("⏰ Operación GET_TIME: T0 recibido %d, Hora local calculada: %d en el seguidor: %s ", T0, currentTime, f.aAbstractNode.Name) // Traza para tiempo
+		T1 = fromWire(T1, version)
 
 		// Llamada a la función que maneja el mensaje del líder y muestra su mensaje
-		TP := f.displayLeaderMessage(T0, currentTime)
+		f.displayLeaderMessage(T1, T2)
+
+		// Anotar la hora de envío de la respuesta (T3) justo antes de responder, con el tiempo de
+		// procesamiento medido con la lectura monotónica
+		T3 := T2 + int64(f.clock.Monotonic()-received)
+		log.Printf("⏰ Operación GET_TIME: T1 recibido %d, T2 %d, T3 %d ns en el seguidor: %s ", T1, T2, T3, f.aAbstractNode.Name) // Traza para tiempo
 
-		// Responder con el formato esperado
-		return fmt.Sprintf(`{"followerName":"%s", "localTime":"%d", "addressFollower":"%s"}`, f.aAbstractNode.Name, TP, f.aAbstractNode.Address), nil
+		// Responder con el formato esperado, en la versión del líder
+		return f.stepReply(T2, T3, version), nil
 	case "UPDATE_TIME":
-		delta := int64(data["delta"].(float64))
-		log.Printf("🔄 Operación UPDATE_TIME: Delta recibido: %d en el seguidor: %s", delta, f.aAbstractNode.Name) // Traza para delta
+		version := messageVersion(data)
+		delta, err := wireInt64(data, "delta")
+		if err != nil {
+			log.Printf("Error al leer data[\"delta\"]: %v", err)
+			return `{"error":"Falta el campo delta"}`, nil
+		}
+		delta = fromWire(delta, version)
+		log.Printf("🔄 Operación UPDATE_TIME: Delta recibido: %v en el seguidor: %s", time.Duration(delta), f.aAbstractNode.Name) // Traza para delta
+
+		id, _ := data["id"].(string)
+		reply, applied := f.updates.apply(id, func() string {
+			// La corrección de frecuencia es opcional: sólo llega cuando el líder ha estimado la deriva
+			if rate, ok := data["rate"].(float64); ok && rate != 0 {
+				f.adjustRate(rate)
+			}
 
-		// Modificar el sistema según el delta
-		return f.modSystemTime(delta), nil
+			// Modificar el sistema según el delta
+			return f.modSystemTime(delta, version)
+		})
+		if !applied {
+			log.Printf("🔁 Corrección %s repetida en el seguidor %s: no se vuelve a aplicar", id, f.aAbstractNode.Name)
+		}
+		return reply, nil
 	case "CLOSE":
-		log.Printf("🔌 Operación CLOSE: Cerrando seguidor %s", f.aAbstractNode.Name) // Traza para CLOSE
+		f.handleClose()
 		return fmt.Sprintf(`{"followerName":"%s","operation":"CLOSE"}`, f.aAbstractNode.Name), nil
+	case "ELECTION":
+		return f.handleElection(data), nil
+	case "COORDINATOR":
+		return f.handleCoordinator(data), nil
+	case "ACK_UPDATE":
+		// Las confirmaciones van al líder en el que se ha convertido el seguidor, si ganó una elección
+		if leader := f.PromotedLeader(); leader != nil {
+			return leader.HandleProcess(message)
+		}
+		log.Printf("Confirmación recibida en el seguidor %s, que no es líder", f.aAbstractNode.Name)
+		return `{"operation":"ACK_IGNORED"}`, nil
 	default:
 		log.Printf("Operación no reconocida en el mensaje del líder: %s", operation) // Traza para operación no reconocida
 		return `{"error":"Operación no reconocida"}`, nil
 	}
 }
 
-// displayLeaderMessage muestra el mensaje del líder y calcula un tiempo promedio (TP).
-func (f *Follower) displayLeaderMessage(T0, localTime int64) int64 {
-	// log.Printf("Mensaje del líder con dirección (%s) recibido: %s con T0 %s", leaderAddr, leaderMessage, time.UnixMilli(T0).String())
-	TP := (T0 + localTime) / 2
-	log.Printf("TP (TP := (T0 + localTime) / 2) del seguidor: (%s, %s) es %s", f.aAbstractNode.Name, f.aAbstractNode.Address, time.UnixMilli(TP).String())
-	return TP
+// HandleAsync implementa AsyncHandler: atiende las notificaciones del líder que llegan sin esperar
+// respuesta. CLOSE cierra el seguidor como por el canal de peticiones y HEARTBEAT indica que el líder
+// sigue vivo entre rondas. COORDINATOR se atiende como por el canal de peticiones y, si el seguidor
+// ganó una elección, las confirmaciones pasan al líder promovido.
+func (f *Follower) HandleAsync(message string) {
+	var data map[string]interface{}
+	if err := json.Unmarshal([]byte(message), &data); err != nil {
+		log.Printf("Error al deserializar el mensaje asíncrono: %v", err)
+		return
+	}
+	operation, _ := data["operation"].(string)
+
+	switch operation {
+	case "CLOSE":
+		f.handleClose()
+	case "HEARTBEAT":
+		f.markLeaderContact()
+	case "COORDINATOR":
+		f.handleCoordinator(data)
+	case "ACK_UPDATE":
+		if leader := f.PromotedLeader(); leader != nil {
+			leader.HandleAsync(message)
+		}
+	default:
+		log.Printf("Operación asíncrona no reconocida en el seguidor %s: %s", f.aAbstractNode.Name, operation)
+	}
+}
+
+// handleClose atiende el cierre del grupo ordenado por el líder.
+func (f *Follower) handleClose() {
+	log.Printf("🔌 Operación CLOSE: Cerrando seguidor %s", f.aAbstractNode.Name) // Traza para CLOSE
+	// El líder cierra el grupo a propósito: su silencio a partir de ahora no debe provocar una elección
+	f.stopElectionWatchdog()
 }
 
-// modSystemTime modifica el tiempo local del sistema basado en un delta.
-func (f *Follower) modSystemTime(delta int64) string {
-	currentLocalTime := time.Now().UnixMilli()
-	modSystemTime := currentLocalTime + delta
-	log.Printf("Tiempo del seguidor %s modificado de %s a %s", f.aAbstractNode.Name, time.UnixMilli(currentLocalTime).String(), time.UnixMilli(modSystemTime).String())
-	return fmt.Sprintf(`{"followerName":"%s", "localTime":"%d","operation":"OK_MOD_TIME"}`, f.aAbstractNode.Name, modSystemTime)
+// timeReply construye la respuesta a GET_TIME con la hora de recepción (T2) y de envío (T3), en
+// nanosegundos, para un líder de la versión indicada.
+// 'localTime' se mantiene para los líderes que sólo leen ese campo.
+func (f *Follower) timeReply(T2, T3 int64, version int) string {
+	return timeReplyMessage(f.aAbstractNode.Name, f.aAbstractNode.Address, T2, T3, version, nil)
 }
 
-// getCurrentTime obtiene la hora actual del sistema en milisegundos desde la época Unix.
+// timeReplyMessage construye la respuesta a GET_TIME de cualquier nodo que atiende peticiones de tiempo.
+// Las marcas, en nanosegundos, se envían en la unidad de la versión indicada; la versión 1 se responde
+// sin el campo "version", como hacían los nodos anteriores. Si corrected no es nil se añade la corrección
+// de paso en vigor, en nanosegundos, sólo a partir de la versión 2: la versión 1 lo leería en milisegundos.
+func timeReplyMessage(name, address string, T2, T3 int64, version int, corrected *time.Duration) string {
+	T2, T3 = toWire(T2, version), toWire(T3, version)
+	if version < ProtocolVersionNano {
+		return fmt.Sprintf(`{"followerName":"%s", "localTime":"%d", "receiveTime":"%d", "sendTime":"%d", "addressFollower":"%s"}`, name, T3, T2, T3, address)
+	}
+	if corrected != nil {
+		return fmt.Sprintf(`{"followerName":"%s", "localTime":"%d", "receiveTime":"%d", "sendTime":"%d", "addressFollower":"%s", "version":"%d", "corrected":"%d"}`, name, T3, T2, T3, address, version, int64(*corrected))
+	}
+	return fmt.Sprintf(`{"followerName":"%s", "localTime":"%d", "receiveTime":"%d", "sendTime":"%d", "addressFollower":"%s", "version":"%d"}`, name, T3, T2, T3, address, version)
+}
+
+// stepReply construye la respuesta a GET_TIME del seguidor. Si corrige su reloj corregido y no el de la
+// máquina, informa además de la corrección de paso en vigor, para que el líder estime la deriva sólo con
+// lo que el seguidor ha aplicado de verdad y no con lo que le ha enviado.
+func (f *Follower) stepReply(T2, T3 int64, version int) string {
+	if !f.correctsVirtualClock() {
+		return f.timeReply(T2, T3, version)
+	}
+	corrected := f.clock.StepOffset()
+	return timeReplyMessage(f.aAbstractNode.Name, f.aAbstractNode.Address, T2, T3, version, &corrected)
+}
+
+// displayLeaderMessage muestra el mensaje del líder con la hora de envío del líder (T1) y la de recepción en el seguidor (T2).
+func (f *Follower) displayLeaderMessage(T1, T2 int64) {
+	log.Printf("Mensaje del líder recibido por el seguidor (%s, %s): T1 %s, T2 %s", f.aAbstractNode.Name, f.aAbstractNode.Address, time.Unix(0, T1).String(), time.Unix(0, T2).String())
+}
+
+// modSystemTime aplica el delta recibido del líder, en nanosegundos, sobre el reloj corregido del seguidor.
+// El cambio persiste: las lecturas posteriores, incluidas las respuestas a GET_TIME, ya lo incluyen.
+// Según AdjustMode la corrección se aplica de golpe o se reparte en el tiempo. Si el seguidor tiene un
+// Adjuster, la corrección se aplica al reloj de la máquina a través de él, y además al reloj corregido
+// si el Adjuster no cambia el de la máquina. La respuesta lleva la hora local en la unidad de la versión
+// del líder.
+func (f *Follower) modSystemTime(delta int64, version int) string {
+	currentLocalTime := f.clock.Now().UnixNano()
+	correction := time.Duration(delta)
+	if f.Adjuster != nil {
+		var err error
+		if f.shouldSlew(delta) {
+			err = f.Adjuster.Slew(correction)
+		} else {
+			err = f.Adjuster.Step(correction)
+		}
+		if err != nil {
+			log.Printf("Error al modificar el reloj del sistema en el seguidor %s: %v", f.aAbstractNode.Name, err)
+			return fmt.Sprintf(`{"followerName":"%s", "localTime":"%d","operation":"ERROR_MOD_TIME","error":%q}`, f.aAbstractNode.Name, toWire(currentLocalTime, version), err.Error())
+		}
+	}
+	if f.correctsVirtualClock() {
+		f.adjustVirtualClock(delta)
+	}
+	modSystemTime := f.clock.Now().UnixNano()
+	log.Printf("Tiempo del seguidor %s modificado de %s a %s (corrección acumulada %v)", f.aAbstractNode.Name, time.Unix(0, currentLocalTime).String(), time.Unix(0, modSystemTime).String(), f.clock.Offset())
+	return fmt.Sprintf(`{"followerName":"%s", "localTime":"%d","operation":"OK_MOD_TIME"}`, f.aAbstractNode.Name, toWire(modSystemTime, version))
+}
+
+// adjustVirtualClock aplica la corrección, en nanosegundos, al reloj corregido, de golpe o repartida en el tiempo.
+func (f *Follower) adjustVirtualClock(delta int64) {
+	correction := time.Duration(delta)
+	if f.shouldSlew(delta) {
+		f.clock.Slew(correction, f.MaxSlewRate)
+		log.Printf("Corrección gradual de %v en el seguidor %s (pendiente %v)", correction, f.aAbstractNode.Name, f.clock.SlewRemaining())
+		return
+	}
+	// La corrección se midió con el reloj ya corregido: lo que quedara de una gradual anterior sobra
+	if dropped := f.clock.StopSlew(); dropped != 0 {
+		log.Printf("Corrección gradual pendiente de %v descartada en el seguidor %s", dropped, f.aAbstractNode.Name)
+	}
+	f.clock.Adjust(correction)
+}
+
+// correctsVirtualClock indica si las correcciones de paso y graduales se aplican al reloj corregido:
+// sin Adjuster o con uno que no cambia el reloj de la máquina. Si el seguidor respondiera OK_MOD_TIME
+// sin cambiar ningún reloj, el líder le reenviaría la misma corrección en cada ronda.
+func (f *Follower) correctsVirtualClock() bool {
+	return f.Adjuster == nil || !f.Adjuster.ChangesSystemClock()
+}
+
+// shouldSlew decide si una corrección se reparte en el tiempo: en SlewMode las negativas siempre,
+// para no hacer retroceder el reloj, y las positivas cuando superan StepLimit.
+func (f *Follower) shouldSlew(delta int64) bool {
+	if f.AdjustMode != SlewMode {
+		return false
+	}
+	return delta < 0 || delta > int64(f.StepLimit)
+}
+
+// SetRetryPolicy cambia la política de reintentos de las peticiones que envía el seguidor. Los mensajes
+// de la elección de líder no se reintentan.
+func (f *Follower) SetRetryPolicy(policy RetryPolicy) {
+	f.aAbstractNode.RetryPolicy = policy
+}
+
+// adjustRate suma una corrección de frecuencia, en partes por millón, a la que el seguidor ya aplicaba.
+func (f *Follower) adjustRate(rate float64) {
+	f.clock.AdjustRate(rate)
+	log.Printf("Corrección de frecuencia del seguidor %s: %.3f ppm (acumulada %.3f ppm)", f.aAbstractNode.Name, rate, f.clock.Rate())
+}
+
+// getCurrentTime obtiene la hora corregida del seguidor en nanosegundos desde la época Unix.
 func (f *Follower) getCurrentTime() int64 {
-	currentTime := time.Now().UnixMilli()
-	log.Printf("Fecha y hora local del seguidor: TP: %s", time.UnixMilli(currentTime).String())
+	currentTime := f.clock.Now().UnixNano()
+	log.Printf("Fecha y hora local del seguidor: TP: %s", time.Unix(0, currentTime).String())
 	return currentTime
 }
 
-// StartAlgorithm configura e inicia el socket REP para escuchar mensajes entrantes
-// y delega la responsabilidad de iniciar la escucha al nodo abstracto.
-// StartAlgorithm configura e inicia la escucha en el seguidor
+// Clock devuelve el reloj corregido del seguidor para que el código que lo incrusta pueda leerlo.
+func (f *Follower) Clock() *VirtualClock {
+	return f.clock
+}
+
+// CurrentTime devuelve la hora corregida del seguidor.
+func (f *Follower) CurrentTime() time.Time {
+	return f.clock.Now()
+}
+
+// StartAlgorithm configura e inicia la escucha en el seguidor y, si ElectionTimeout es mayor que cero,
+// la vigilancia del líder para elegir uno nuevo cuando deje de comunicarse.
 func (f *Follower) StartAlgorithm() error {
 	// Llama a StartListening, que se encargará de la creación y enlace del socket.
 	log.Printf("Iniciando algoritmo para el seguidor %s en %s", f.aAbstractNode.Name, f.aAbstractNode.Address)
-	return f.aAbstractNode.StartListening()
+	if err := f.aAbstractNode.StartListening(); err != nil {
+		return err
+	}
+	if f.UDPProbes {
+		if err := f.aAbstractNode.StartProbeListener(); err != nil {
+			// Sin sondas el seguidor no arranca: deja de escuchar también por el transporte
+			f.aAbstractNode.Close()
+			return err
+		}
+	}
+	// Sin canal de difusión el seguidor recibe las correcciones por el canal de peticiones
+	if f.BroadcastAddress != "" {
+		if err := f.subscribe(); err != nil {
+			log.Printf("Seguidor %s sin canal de difusión: %v", f.aAbstractNode.Name, err)
+		}
+	}
+
+	// Vigilar al líder para sustituirlo si deja de comunicarse
+	if f.ElectionTimeout > 0 {
+		go f.watchLeader()
+	}
+	return nil
 }
@@ -6,30 +6,45 @@ import (
 )
 
 // FollowerInfo encapsula información sobre un nodo seguidor en el sistema.
+// Las marcas de tiempo siguen el intercambio de NTP: T1 y T4 se toman con el reloj del líder
+// y T2 y T3 con el del seguidor. Marcas, diferencias y retardos están en nanosegundos.
 type FollowerInfo struct {
 	Name              string        // Nombre del seguidor
 	Address           string        // Dirección del seguidor en formato "host:puerto"
 	State             FollowerState // Estado actual del seguidor
-	CurrentTime       int64         // T0: Hora inicial del líder
-	FollowerTime      int64         // Hora local del seguidor en milisegundos desde la época UNIX
-	CommunicationTime int64         // Tiempo de comunicación entre líder y seguidor en milisegundos
-	TripTime          int64         // Tiempo de ida y vuelta (triptime) estimado
-	DiffTime          int64         // Diferencia de tiempo calculada entre líder y seguidor
-	Delta             int64         // Diferencia global de tiempo (delta) aplicada al seguidor
+	CurrentTime       int64         // T1: Hora del líder al enviar la solicitud
+	ReceiveTime       int64         // T2: Hora del seguidor al recibir la solicitud
+	FollowerTime      int64         // T3: Hora local del seguidor al enviar la respuesta, en nanosegundos desde la época UNIX
+	ArrivalTime       int64         // T4: Hora del líder al recibir la respuesta
+	CommunicationTime int64         // Tiempo total del intercambio (T4 - T1)
+	Delay             int64         // Retardo de red de ida y vuelta sin el procesamiento del seguidor: (T4 - T1) - (T3 - T2)
+	TripTime          int64         // Tiempo de viaje en un sentido estimado (Delay / 2)
+	DiffTime          int64         // Diferencia de tiempo calculada entre líder y seguidor: ((T2 - T1) + (T3 - T4)) / 2
+	Delta             int64         // Corrección de tiempo (delta) calculada para este seguidor y aplicada en él
+	Samples           int           // Número de muestras válidas entre las que se eligió la de menor retardo
+	Drift             float64       // Deriva estimada del reloj del seguidor respecto al líder en partes por millón
+	RateCorrection    float64       // Corrección de frecuencia enviada al seguidor en partes por millón
+	Corrected         *int64        // Corrección de paso en vigor que informó el seguidor (nil si no la informa)
+	Version           int           // Versión del protocolo con la que respondió el seguidor
+	Attempts          int           // Intentos que necesitó la petición de tiempo de la muestra elegida
 }
 
-// NewFollowerInfo crea un nuevo objeto FollowerInfo con los valores proporcionados.
-func NewFollowerInfo(address, name string, followerTime, currentTimer, communicationTime, nowTime int64) *FollowerInfo {
-	tripTime := (nowTime - currentTimer) / 2
-	diffTime := (followerTime + tripTime) - nowTime
+// NewFollowerInfo crea un nuevo objeto FollowerInfo a partir de las cuatro marcas de tiempo del intercambio.
+// La diferencia de reloj y el retardo se calculan como en NTP, suponiendo que la ida y la vuelta tardan lo mismo.
+func NewFollowerInfo(address, name string, t1, t2, t3, t4 int64) *FollowerInfo {
+	delay := (t4 - t1) - (t3 - t2)
+	diffTime := ((t2 - t1) + (t3 - t4)) / 2
 
 	return &FollowerInfo{
 		Name:              name,
 		Address:           address,
-		FollowerTime:      followerTime,
-		CurrentTime:       currentTimer,
-		CommunicationTime: communicationTime,
-		TripTime:          tripTime,
+		CurrentTime:       t1,
+		ReceiveTime:       t2,
+		FollowerTime:      t3,
+		ArrivalTime:       t4,
+		CommunicationTime: t4 - t1,
+		Delay:             delay,
+		TripTime:          delay / 2,
 		DiffTime:          diffTime,
 		State:             RequestNotSent, // Estado inicial por defecto
 	}
@@ -55,22 +70,27 @@ func (f *FollowerInfo) GetFollowerTime() int64 {
 	return f.FollowerTime
 }
 
-// CurrentTime devuelve la hora T0 del líder.
+// GetCurrentTime devuelve la hora T1 del líder.
 func (f *FollowerInfo) GetCurrentTime() int64 {
 	return f.CurrentTime
 }
 
+// GetDelay devuelve el retardo de red de ida y vuelta sin el tiempo de procesamiento del seguidor.
+func (f *FollowerInfo) GetDelay() int64 {
+	return f.Delay
+}
+
 // GetCommunicationTime devuelve el tiempo de comunicación entre líder y seguidor.
 func (f *FollowerInfo) GetCommunicationTime() int64 {
 	return f.CommunicationTime
 }
 
-// GetDelta devuelve la diferencia global de tiempo (delta).
+// GetDelta devuelve la corrección de tiempo (delta) aplicada al seguidor.
 func (f *FollowerInfo) GetDelta() int64 {
 	return f.Delta
 }
 
-// SetDelta establece la diferencia global de tiempo (delta) para el seguidor.
+// SetDelta establece la corrección de tiempo (delta) del seguidor.
 func (f *FollowerInfo) SetDelta(delta int64) {
 	f.Delta = delta
 }
@@ -87,13 +107,15 @@ func (f *FollowerInfo) SetState(state FollowerState) {
 
 // String genera una representación en cadena del objeto FollowerInfo.
 func (f *FollowerInfo) String() string {
-	// Convertir DateFollower de int64 (timestamp en milisegundos) a time.Time
-	timestampFollower := time.Unix(0, f.GetFollowerTime()*int64(time.Millisecond))
-	timestampLeader := time.Unix(0, f.GetCurrentTime()*int64(time.Millisecond))
+	// Convertir DateFollower de int64 (timestamp en nanosegundos) a time.Time
+	timestampFollower := time.Unix(0, f.GetFollowerTime())
+	timestampLeader := time.Unix(0, f.GetCurrentTime())
 	// Usar el formato adecuado para la fecha
 	return fmt.Sprintf("Nombre: %s, Estado: %s, Hora local del Seguidor: %d, Fecha: %s, "+
-		"Hora inicial T0 del líder: %d, Fecha: %s,  Tiempo de comunicación: %d ms, TripTime: %d ms, "+
-		"Diferencia de tiempo: %d ms, Diferencia global (delta): %d ms, Dirección: %s",
-		f.Name, f.State, f.FollowerTime, timestampFollower.Format("2006-01-02 15:04:05"), // Formato para la fecha
-		f.CurrentTime, timestampLeader, f.CommunicationTime, f.TripTime, f.DiffTime, f.Delta, f.Address)
+		"Hora T1 del líder: %d, Fecha: %s,  Tiempo de comunicación: %v, Retardo: %v, TripTime: %v, "+
+		"Diferencia de tiempo: %v, Corrección (delta): %v, Muestras: %d, Deriva: %.3f ppm, "+
+		"Corrección de frecuencia: %.3f ppm, Versión: %d, Intentos: %d, Dirección: %s",
+		f.Name, f.State, f.FollowerTime, timestampFollower.Format("2006-01-02 15:04:05.000000000"), // Formato para la fecha
+		f.CurrentTime, timestampLeader, time.Duration(f.CommunicationTime), time.Duration(f.Delay), time.Duration(f.TripTime),
+		time.Duration(f.DiffTime), time.Duration(f.Delta), f.Samples, f.Drift, f.RateCorrection, f.Version, f.Attempts, f.Address)
 }
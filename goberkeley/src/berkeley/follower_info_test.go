@@ -0,0 +1,27 @@
+package berkeley
+
+import "testing"
+
+func TestNewFollowerInfo(t *testing.T) {
+	tests := []struct {
+		name           string
+		t1, t2, t3, t4 int64
+		diff, delay    int64
+	}{
+		{name: "relojes iguales", t1: 100, t2: 105, t3: 107, t4: 112, diff: 0, delay: 10},
+		{name: "seguidor adelantado", t1: 100, t2: 155, t3: 157, t4: 112, diff: 50, delay: 10},
+		{name: "seguidor atrasado", t1: 100, t2: 75, t3: 77, t4: 112, diff: -30, delay: 10},
+		{name: "procesamiento largo", t1: 100, t2: 105, t3: 505, t4: 510, diff: 0, delay: 10},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info := NewFollowerInfo("addr", "F", tt.t1, tt.t2, tt.t3, tt.t4)
+			if info.DiffTime != tt.diff {
+				t.Errorf("diferencia %d, se esperaba %d", info.DiffTime, tt.diff)
+			}
+			if info.Delay != tt.delay || info.TripTime != tt.delay/2 {
+				t.Errorf("retardo %d y viaje %d, se esperaba %d y %d", info.Delay, info.TripTime, tt.delay, tt.delay/2)
+			}
+		})
+	}
+}
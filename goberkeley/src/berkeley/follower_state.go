@@ -13,6 +13,9 @@ const (
 	// CONNECTION_ERROR indica que hubo un error de conexión al intentar comunicarse con el seguidor.
 	ConnectionError FollowerState = "CONNECTION_ERROR"
 
+	// RTT_EXCEEDED indica que el seguidor respondió, pero todas sus muestras superaron el tiempo de ida y vuelta máximo.
+	RTTExceeded FollowerState = "RTT_EXCEEDED"
+
 	// REQUEST_NOT_SENT indica que la solicitud de actualización de la hora no fue enviada debido a algún error.
 	RequestNotSent FollowerState = "REQUEST_NOT_SENT"
 
@@ -25,6 +28,12 @@ const (
 	// TIME_UPDATED indica que la hora del sistema del seguidor se actualizó correctamente.
 	TimeUpdated FollowerState = "TIME_UPDATED"
 
+	// REJECTED indica que la diferencia de tiempo del seguidor se alejaba del resto más que el umbral y no entró en la media.
+	Rejected FollowerState = "REJECTED"
+
+	// FALSETICKER indica que el intervalo de tiempo del seguidor no contenía el intervalo acordado por la mayoría de fuentes.
+	Falseticker FollowerState = "FALSETICKER"
+
 	// ERROR_CLOSE indica que  no pude enviar el cierre del socket en el seguidor.
 	ErrorClose FollowerState = "ERROR_CLOSE"
 
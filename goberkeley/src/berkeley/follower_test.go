@@ -0,0 +1,168 @@
+package berkeley
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFollowerCorrectionsPersist(t *testing.T) {
+	follower, err := NewFollower("F", "127.0.0.1:0", "127.0.0.1:0", 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	updates := []struct {
+		message string
+		offset  time.Duration
+		rate    float64
+	}{
+		{message: `{"operation":"UPDATE_TIME","delta":250}`, offset: 250 * time.Millisecond},
+		{message: `{"operation":"UPDATE_TIME","delta":-100,"rate":12.5}`, offset: 150 * time.Millisecond, rate: 12.5},
+		{message: `{"operation":"UPDATE_TIME","delta":0,"rate":-2.5}`, offset: 150 * time.Millisecond, rate: 10},
+	}
+	for _, update := range updates {
+		reply, err := follower.HandleProcess(update.message)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(reply, "OK_MOD_TIME") {
+			t.Fatalf("respuesta %s, se esperaba OK_MOD_TIME", reply)
+		}
+		// La corrección de frecuencia avanza con el reloj real entre una actualización y la siguiente
+		if got := follower.Clock().Offset(); (got - update.offset).Abs() > time.Millisecond {
+			t.Errorf("corrección acumulada %v, se esperaba %v", got, update.offset)
+		}
+		if got := follower.Clock().Rate(); got != update.rate {
+			t.Errorf("corrección de frecuencia %v, se esperaba %v", got, update.rate)
+		}
+	}
+}
+
+// recordingAdjuster es un ClockAdjuster de prueba que registra las correcciones recibidas. Si Err no es
+// nil, CheckCapability y todas las correcciones fallan con ese error.
+type recordingAdjuster struct {
+	Err    error           // Error que devuelven todas las operaciones, opcional
+	System bool            // Valor de ChangesSystemClock
+	Steps  []time.Duration // Correcciones de paso recibidas
+	Slews  []time.Duration // Correcciones graduales recibidas
+}
+
+func (a *recordingAdjuster) CheckCapability() error {
+	return a.Err
+}
+
+func (a *recordingAdjuster) Step(delta time.Duration) error {
+	if a.Err != nil {
+		return a.Err
+	}
+	a.Steps = append(a.Steps, delta)
+	return nil
+}
+
+func (a *recordingAdjuster) Slew(delta time.Duration) error {
+	if a.Err != nil {
+		return a.Err
+	}
+	a.Slews = append(a.Slews, delta)
+	return nil
+}
+
+func (a *recordingAdjuster) ChangesSystemClock() bool {
+	return a.System
+}
+
+func newAdjustedFollower(t *testing.T, mode AdjustMode, adjuster ClockAdjuster) *Follower {
+	t.Helper()
+	follower, err := NewFollowerWithClock("F", "127.0.0.1:0", "127.0.0.1:0", 1000, NewManualClock(time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	follower.AdjustMode = mode
+	follower.StepLimit = 100 * time.Millisecond
+	follower.Adjuster = adjuster
+	return follower
+}
+
+func TestFollowerAdjusterStepMode(t *testing.T) {
+	adjuster := &recordingAdjuster{System: true}
+	follower := newAdjustedFollower(t, StepMode, adjuster)
+
+	for _, delta := range []time.Duration{250 * time.Millisecond, -40 * time.Millisecond} {
+		if reply := follower.modSystemTime(int64(delta), ProtocolVersion); !strings.Contains(reply, "OK_MOD_TIME") {
+			t.Fatalf("respuesta %s, se esperaba OK_MOD_TIME", reply)
+		}
+	}
+	if want := []time.Duration{250 * time.Millisecond, -40 * time.Millisecond}; !equalDurations(adjuster.Steps, want) {
+		t.Errorf("correcciones de paso %v, se esperaba %v", adjuster.Steps, want)
+	}
+	if len(adjuster.Slews) != 0 {
+		t.Errorf("correcciones graduales %v, no se esperaba ninguna", adjuster.Slews)
+	}
+	// El reloj de la máquina ya incluye las correcciones: el corregido no debe aplicarlas otra vez
+	if got := follower.Clock().Offset(); got != 0 {
+		t.Errorf("corrección del reloj corregido %v, se esperaba 0", got)
+	}
+}
+
+func TestFollowerAdjusterSlewMode(t *testing.T) {
+	adjuster := &recordingAdjuster{System: true}
+	follower := newAdjustedFollower(t, SlewMode, adjuster)
+
+	// Las negativas y las positivas por encima de StepLimit se reparten; el resto se aplica de golpe
+	for _, delta := range []time.Duration{-30 * time.Millisecond, 50 * time.Millisecond, 400 * time.Millisecond} {
+		follower.modSystemTime(int64(delta), ProtocolVersion)
+	}
+	if want := []time.Duration{-30 * time.Millisecond, 400 * time.Millisecond}; !equalDurations(adjuster.Slews, want) {
+		t.Errorf("correcciones graduales %v, se esperaba %v", adjuster.Slews, want)
+	}
+	if want := []time.Duration{50 * time.Millisecond}; !equalDurations(adjuster.Steps, want) {
+		t.Errorf("correcciones de paso %v, se esperaba %v", adjuster.Steps, want)
+	}
+}
+
+func TestFollowerAdjusterWithoutSystemClock(t *testing.T) {
+	adjuster := &recordingAdjuster{}
+	follower := newAdjustedFollower(t, StepMode, adjuster)
+
+	// Un Adjuster que no cambia el reloj de la máquina deja que la corrección llegue al reloj corregido
+	follower.modSystemTime(int64(120*time.Millisecond), ProtocolVersion)
+	if got := follower.Clock().Offset(); got != 120*time.Millisecond {
+		t.Errorf("corrección del reloj corregido %v, se esperaba 120ms", got)
+	}
+	if len(adjuster.Steps) != 1 {
+		t.Errorf("correcciones de paso %v, se esperaba una", adjuster.Steps)
+	}
+}
+
+func TestFollowerAdjusterWithoutCapability(t *testing.T) {
+	adjuster := &recordingAdjuster{Err: ErrClockPermission, System: true}
+	follower := newAdjustedFollower(t, SlewMode, adjuster)
+
+	if err := follower.Adjuster.CheckCapability(); !errors.Is(err, ErrClockPermission) {
+		t.Fatalf("CheckCapability devolvió %v, se esperaba ErrClockPermission", err)
+	}
+	reply := follower.modSystemTime(int64(-30*time.Millisecond), ProtocolVersion)
+	if !strings.Contains(reply, "ERROR_MOD_TIME") {
+		t.Errorf("respuesta %s, se esperaba ERROR_MOD_TIME", reply)
+	}
+	if got := follower.Clock().Offset(); got != 0 {
+		t.Errorf("corrección del reloj corregido %v, se esperaba 0", got)
+	}
+	if len(adjuster.Slews) != 0 || len(adjuster.Steps) != 0 {
+		t.Errorf("correcciones registradas %v %v, no se esperaba ninguna", adjuster.Steps, adjuster.Slews)
+	}
+}
+
+func equalDurations(got, want []time.Duration) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			return false
+		}
+	}
+	return true
+}
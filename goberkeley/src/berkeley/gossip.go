@@ -0,0 +1,119 @@
+package berkeley
+
+import (
+	"context"
+	"log"
+	"math/rand"
+	"time"
+)
+
+// Algoritmos de sincronización disponibles en la configuración.
+const (
+	// BerkeleyAlgorithm sincroniza el grupo desde un líder (opción por defecto).
+	BerkeleyAlgorithm = "berkeley"
+	// GossipAlgorithm sincroniza el grupo sin líder: cada nodo se acerca a la media de unos pares al azar.
+	GossipAlgorithm = "gossip"
+)
+
+// DefaultGossipFanout es el número de pares que consulta un nodo gossip en cada ronda si no se indica otro.
+const DefaultGossipFanout = 3
+
+// DefaultGossipGain es la fracción de la diferencia media que un nodo gossip corrige en cada ronda si no
+// se indica otra. Corregir sólo una parte evita que los nodos oscilen al moverse todos a la vez.
+const DefaultGossipGain = 0.5
+
+// GossipNode es un nodo del modo de sincronización sin líder. Ningún nodo tiene un papel especial: todos
+// atienden GET_TIME y, en cada ronda, piden la hora a unos pocos pares elegidos al azar y mueven su reloj
+// corregido hacia la media de las diferencias, contando la suya (0). Como todos hacen lo mismo, los
+// relojes del grupo convergen sin que nadie coordine las rondas.
+type GossipNode struct {
+	*peerNode
+	random *rand.Rand
+
+	// Fanout es el número de pares consultados en cada ronda (DefaultGossipFanout si es 0).
+	Fanout int
+	// Gain es la fracción, entre 0 y 1, de la diferencia media que se corrige en cada ronda (DefaultGossipGain si es 0).
+	Gain float64
+}
+
+// NewGossipNode crea un nodo gossip que escucha en address y conoce a los pares indicados
+// (nombre -> dirección). Las correcciones se aplican sobre un VirtualClock construido encima del
+// reloj indicado, o sobre el propio reloj si ya es un VirtualClock.
+func NewGossipNode(name, address string, timeout time.Duration, peers map[string]string, clock Clock) (*GossipNode, error) {
+	base, err := newPeerNode("gossip", name, address, timeout, peers, clock)
+	if err != nil {
+		return nil, err
+	}
+	return &GossipNode{peerNode: base, random: rand.New(rand.NewSource(time.Now().UnixNano()))}, nil
+}
+
+// RunPeriodic ejecuta una ronda de gossip cada intervalo (interval) hasta que se cancela el contexto.
+// Conviene que los nodos no arranquen todos a la vez para repartir las rondas en el tiempo.
+func (g *GossipNode) RunPeriodic(ctx context.Context, interval time.Duration) error {
+	return g.runPeriodic(ctx, interval, g.RunRound)
+}
+
+// RunRound ejecuta una ronda de gossip: pide la hora a Fanout pares al azar, calcula la media de las
+// diferencias de reloj incluyendo la propia y corrige el reloj una fracción Gain de esa media.
+// Devuelve la corrección aplicada en nanosegundos.
+func (g *GossipNode) RunRound() int64 {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.Rounds++
+	peers := g.selectPeers()
+	if len(peers) == 0 {
+		log.Printf("Nodo gossip %s sin pares que consultar", g.aAbstractNode.Name)
+		return 0
+	}
+
+	// Media de las diferencias con los pares que respondieron; la del propio nodo es 0
+	var sum int64
+	responded := 0
+	for _, peer := range g.samplePeers(peers) {
+		if peer.State != Responded {
+			log.Printf("Par %s sin muestra válida en la ronda %d de %s (%s)", peer.Name, g.Rounds, g.aAbstractNode.Name, peer.State)
+			continue
+		}
+		sum += peer.DiffTime
+		responded++
+	}
+	if responded == 0 {
+		log.Printf("Ningún par respondió en la ronda %d de %s", g.Rounds, g.aAbstractNode.Name)
+		return 0
+	}
+
+	gain := g.Gain
+	if gain <= 0 || gain > 1 {
+		gain = DefaultGossipGain
+	}
+	average := float64(sum) / float64(responded+1)
+	correction := int64(gain * average)
+	g.adjustClock(correction)
+	log.Printf("Ronda %d de %s: %d pares, diferencia media %v, corrección %v", g.Rounds, g.aAbstractNode.Name, responded, time.Duration(average), time.Duration(correction))
+	return correction
+}
+
+// selectPeers elige al azar hasta Fanout pares entre los conocidos.
+func (g *GossipNode) selectPeers() map[string]string {
+	fanout := g.Fanout
+	if fanout <= 0 {
+		fanout = DefaultGossipFanout
+	}
+
+	all := g.peers()
+	names := make([]string, 0, len(all))
+	for name := range all {
+		names = append(names, name)
+	}
+	g.random.Shuffle(len(names), func(i, j int) { names[i], names[j] = names[j], names[i] })
+	if len(names) > fanout {
+		names = names[:fanout]
+	}
+
+	peers := make(map[string]string, len(names))
+	for _, name := range names {
+		peers[name] = all[name]
+	}
+	return peers
+}
@@ -1,27 +1,37 @@
 package berkeley
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 
 	"log"
+	"sort"
 	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
 // Mensaje JSON que se envía al seguidor.
+// Las marcas de tiempo y las correcciones van en la unidad de la versión del protocolo (Version).
 type TimeRequest struct {
 	Message    string `json:"message"`
 	Operation  string `json:"operation"`
-	Time       int64  `json:"time"`
+	Time       int64  `json:"time"` // T1: hora del líder al enviar la solicitud
 	LeaderAddr string `json:"leader_address"`
+	Round      int    `json:"round,omitempty"`   // Ronda del líder en modo periódico
+	Version    int    `json:"version,omitempty"` // Versión del protocolo; sin ella, la 1 (milisegundos)
 }
 type DeltaRequest struct {
-	Message    string `json:"message"`
-	Operation  string `json:"operation"`
-	Delta      int64  `json:"delta"`
-	LeaderAddr string `json:"leader_address"`
+	Message    string  `json:"message"`
+	Operation  string  `json:"operation"`
+	Delta      int64   `json:"delta"`
+	Rate       float64 `json:"rate,omitempty"` // Corrección de frecuencia en partes por millón
+	LeaderAddr string  `json:"leader_address"`
+	Version    int     `json:"version,omitempty"` // Versión del protocolo; sin ella, la 1 (milisegundos)
+	ID         string  `json:"id,omitempty"`      // Identificador de la corrección, igual en todos sus reintentos
 }
 type closeRequest struct {
 	Message    string `json:"message"`
@@ -37,12 +47,61 @@ type Leader struct {
 	NonRespondingFollowers map[string]*FollowerInfo
 	TimeUpdatedFollowers   map[string]*FollowerInfo
 	FailedFollowers        map[string]*FollowerInfo
-	//mu                     sync.Mutex // Mutex para proteger los mapas en accesos concurrentes
-	Logger *log.Logger
+	RejectedFollowers      map[string]*FollowerInfo
+	mu                     sync.Mutex // Mutex para proteger los mapas en accesos concurrentes
+	Logger                 *log.Logger
+	ClockOffset            time.Duration // Corrección de paso acumulada del reloj local del líder
+	LeaderDelta            time.Duration // Corrección aplicada por el líder a su propio reloj en la última ronda
+	// OutlierThreshold es la separación máxima entre las diferencias de tiempo que entran en la media
+	// tolerante a fallos. Con 0 se promedian todos los relojes válidos.
+	OutlierThreshold time.Duration
+	// Aggregator es la estrategia que calcula la diferencia objetivo de la ronda. Si es nil se usa la media.
+	Aggregator Aggregator
+	// SamplesPerFollower es el número de muestras de tiempo que se piden a cada seguidor por ronda (1 si es 0).
+	SamplesPerFollower int
+	// MaxRTT es el tiempo de ida y vuelta máximo para aceptar una muestra (0 lo desactiva).
+	MaxRTT time.Duration
+	// Rounds es el número de rondas ejecutadas en modo periódico.
+	Rounds int
+	// DriftWindow es el número de rondas con las que se estima la deriva de cada seguidor (0 la desactiva).
+	DriftWindow int
+	// Agreed es el intervalo acordado en la última ronda si el Aggregator es un IntervalAggregator.
+	Agreed *AgreedInterval
+	// BroadcastAddress es la dirección del canal de difusión por el que el líder anuncia las rondas y
+	// envía las correcciones en lote (vacía lo desactiva). Requiere un transporte que sea Broadcaster.
+	BroadcastAddress string
+	// Params son los parámetros de corrección que se difunden al grupo con cada anuncio de ronda.
+	Params *ClusterParams
+	// Priority es la prioridad del líder en la elección de líder: si otro nodo de mayor prioridad se
+	// anuncia con COORDINATOR, el líder deja de ejecutar rondas.
+	Priority int
+	// SubLeaders son los seguidores que son sub-líderes de su propio grupo. A ellos no se les piden las
+	// muestras de tiempo con sondas UDP.
+	SubLeaders map[string]bool
+
+	driftHistory       map[string][]driftSample // Diferencias libres de las últimas rondas por seguidor
+	appliedCorrections map[string]int64         // Correcciones de paso acumuladas por seguidor
+	clock              *VirtualClock            // Reloj local ajustable del líder
+	updateSeq          uint64                   // Contador de correcciones enviadas, para identificarlas
+	broadcast          Broadcaster              // Canal de difusión, una vez preparado
+	broadcastErr       error                    // Motivo por el que no se pudo preparar el canal de difusión
+	routed             bool                     // Los mensajes al líder llegan a través de otro nodo que ya escucha
+	acks               ackCollector             // Confirmaciones de las correcciones difundidas
+	resigned           chan struct{}            // Se cierra cuando el líder cede el puesto
+	resignOnce         sync.Once
+	listenOnce         sync.Once
+	listenErr          error // Resultado de empezar a escuchar en la dirección del líder
 }
 
-// InitializeLeaderNode crea e inicializa un nuevo nodo líder.
+// InitializeLeaderNode crea e inicializa un nuevo nodo líder sobre el reloj del sistema.
 func InitializeLeaderNode(name, address string, timeout time.Duration, nodeAddresses map[string]string) (*Leader, error) {
+	return InitializeLeaderNodeWithClock(name, address, timeout, nodeAddresses, SystemClock{})
+}
+
+// InitializeLeaderNodeWithClock crea e inicializa un nuevo nodo líder sobre el reloj indicado. Las
+// correcciones del propio líder se aplican sobre un VirtualClock construido encima de ese reloj, o
+// sobre el propio reloj si ya es un VirtualClock.
+func InitializeLeaderNodeWithClock(name, address string, timeout time.Duration, nodeAddresses map[string]string, clock Clock) (*Leader, error) {
 	// Suponiendo que InitializeNodeWithAddresses crea un nodo base y devuelve un puntero a AbstractNode
 	baseNode, err := InitializeNodeWithAddresses(name, address, timeout, nodeAddresses)
 	if err != nil {
@@ -53,11 +112,26 @@ func InitializeLeaderNode(name, address string, timeout time.Duration, nodeAddre
 	leader := &Leader{
 		aAbstractNode: baseNode, // Asignamos el puntero a AbstractNode
 		Logger:        log.Default(),
+		clock:         virtualClockFor(clock),
+		resigned:      make(chan struct{}),
 	}
 	leader.aAbstractNode.Handler = leader
 
 	return leader, nil
 }
+
+// resetStructs sustituye los mapas de resultados por otros vacíos al comienzo de cada ronda.
+func (l *Leader) resetStructs() {
+	l.UnreachableFollowers = nil
+	l.SuccessfulFollowers = nil
+	l.NonRespondingFollowers = nil
+	l.TimeUpdatedFollowers = nil
+	l.FailedFollowers = nil
+	l.RejectedFollowers = nil
+	l.Agreed = nil
+	l.initializeStructs()
+}
+
 func (l *Leader) initializeStructs() {
 	if l.UnreachableFollowers == nil {
 		l.UnreachableFollowers = make(map[string]*FollowerInfo)
@@ -74,50 +148,142 @@ func (l *Leader) initializeStructs() {
 	if l.FailedFollowers == nil {
 		l.FailedFollowers = make(map[string]*FollowerInfo)
 	}
+	if l.RejectedFollowers == nil {
+		l.RejectedFollowers = make(map[string]*FollowerInfo)
+	}
 }
 
 // StartAlgorithm implementa el algoritmo de sincronización Berkeley para el líder.
+// Ejecuta una única ronda y, al terminar, envía el mensaje de cierre a los seguidores actualizados.
 func (l *Leader) StartAlgorithm() {
 	l.Logger.Println("\n\n\t*************** Iniciando algoritmo de sincronización Berkeley... *****************")
 	log.Println(" ")
 	log.Println(" ")
 
-	l.initializeStructs()
-
-	// Simula el envío de solicitudes de tiempo a los seguidores
-	log.Println("\n\n\t** Fase 1 **:  Petición de tiempos a los seguidores y calculo de sus diferncias.")
-	log.Println(" ")
-
-	l.processFollowers()
-
-	// Fase 2: Calcular el delta con la media de los tiempos
-	log.Println("\n\n\t** Fase 2 **: Calcular el delta con la media de los tiempos")
-	log.Println(" ")
-
-	delta := l.calculateDeltaTimeDifference()
+	// El líder escucha en su dirección aunque no difunda: por ella le llegan las confirmaciones, los
+	// anuncios de otros líderes y los mensajes asíncronos
+	if err := l.listen(); err != nil {
+		log.Printf("Error al iniciar la escucha del líder %s: %v", l.aAbstractNode.Name, err)
+		return
+	}
 
-	if delta != 0 {
-		// Paso 3: Actualizar relojes de los seguidores
-		log.Println("\n\n\t** Paso 3 **: Llamar a los seguidores para actualizar sus relojes")
-		log.Println(" ")
+	l.mu.Lock()
+	defer l.mu.Unlock()
 
-		l.callFollowersWithUpdatedTime(delta)
+	// Enlazar el canal de difusión antes de la ronda, para que los seguidores puedan suscribirse a tiempo
+	l.broadcaster()
+	l.initializeStructs()
 
+	if l.runRound(context.Background()) {
 		// Fase 4: Enviar mensaje de cierre
 		log.Println("\n\n\t** Fase 4 **: Enviar mensaje de cierre a los seguidores")
 		log.Println(" ")
 
-		l.sendCloseMessagesToFollowers() //Realmente no es del algoritmo pero para evitar problemas con los contextos de Go y sockets sincronizo el cierre!!!
+		l.sendCloseMessagesToFollowers(l.TimeUpdatedFollowers) //Realmente no es del algoritmo pero para evitar problemas con los contextos de Go y sockets sincronizo el cierre!!!
 
 		// Fase 5: Mostrar los resultados finales
 		log.Println("\n\n\t** Fase 5: Mostrar los resultados de la sincronización")
 		log.Println(" ")
 
 		l.printResults()
-	} else {
+	}
+}
+
+// RunPeriodic ejecuta el líder en modo demonio: repite una ronda de sincronización cada intervalo
+// (interval) sin cerrar las conexiones con los seguidores entre rondas. Antes de cada ronda los mapas
+// de resultados se sustituyen por otros vacíos, de modo que quien conserve los de la ronda anterior no
+// los ve modificados. Cuando se cancela el contexto, también a mitad de una ronda, que se abandona,
+// envía el mensaje de cierre a todos los seguidores y termina sin error. El líder escucha en su
+// dirección para recibir los COORDINATOR: si cede el puesto a otro de mayor prioridad deja de ejecutar
+// rondas, sin cerrar a los seguidores, y espera a que se cancele el contexto.
+func (l *Leader) RunPeriodic(ctx context.Context, interval time.Duration) error {
+	if interval <= 0 {
+		return fmt.Errorf("intervalo de sincronización no válido: %v", interval)
+	}
+	l.Logger.Printf("\n\n\t*************** Iniciando sincronización Berkeley periódica cada %v *****************", interval)
+	if err := l.listen(); err != nil {
+		return err
+	}
+	// Enlazar el canal de difusión antes de la primera ronda, para que los seguidores puedan
+	// suscribirse antes del primer anuncio
+	l.broadcaster()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-l.resigned:
+			log.Printf("El líder %s ha cedido el puesto: no ejecuta más rondas", l.aAbstractNode.Name)
+			<-ctx.Done()
+			return nil
+		default:
+		}
+
+		l.mu.Lock()
+		l.resetStructs()
+		l.Rounds++
+		log.Printf("\n\n\t** Ronda %d **", l.Rounds)
+		if l.runRound(ctx) {
+			l.printResults()
+		}
+		l.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			// Fase de cierre: avisar a todos los seguidores configurados, hayan respondido o no en la última ronda
+			log.Println("\n\n\t** Parada **: Enviar mensaje de cierre a los seguidores")
+			followers := make(map[string]*FollowerInfo, len(l.aAbstractNode.NodeAddresses))
+			for name, address := range l.aAbstractNode.NodeAddresses {
+				followers[name] = &FollowerInfo{Name: name, Address: address}
+			}
+			l.sendCloseMessagesToFollowers(followers)
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// runRound ejecuta las fases de una ronda del algoritmo: petición de tiempos, cálculo de las
+// correcciones y actualización de los relojes. Retorna true si se enviaron correcciones. Si se cancela
+// el contexto durante la petición de tiempos, la ronda se abandona sin enviar ninguna corrección.
+// Quien la llama debe tener bloqueado el mutex del líder.
+func (l *Leader) runRound(ctx context.Context) bool {
+	// Anunciar la ronda por el canal de difusión, si lo hay
+	l.announceRound()
+
+	// Simula el envío de solicitudes de tiempo a los seguidores
+	log.Println("\n\n\t** Fase 1 **:  Petición de tiempos a los seguidores y calculo de sus diferncias.")
+	log.Println(" ")
+
+	l.processFollowers(ctx)
+	if ctx.Err() != nil {
+		log.Println("Ronda interrumpida durante la petición de tiempos: no se envían correcciones.")
+		return false
+	}
+
+	// Fase 2: Calcular la corrección de cada seguidor con la media de los tiempos
+	log.Println("\n\n\t** Fase 2 **: Calcular la corrección de cada seguidor con la media de los tiempos")
+	log.Println(" ")
+
+	corrections, leaderDelta := l.calculateDeltaTimeDifference()
+
+	// Estimar la deriva de cada seguidor con las rondas anteriores
+	l.recordDriftSamples()
+
+	if len(corrections) == 0 {
 		// Se registra esta situación para comprobarlo más adelante en los logs
-		log.Println("El resultado del delta es cero por lo que no se envían actualizaciones a ningún seguidor.")
+		log.Println("No hay correcciones que calcular por lo que no se envían actualizaciones a ningún seguidor.")
+		return false
 	}
+
+	// Paso 3: Actualizar relojes de los seguidores y el del propio líder
+	log.Println("\n\n\t** Paso 3 **: Llamar a los seguidores para actualizar sus relojes")
+	log.Println(" ")
+
+	l.callFollowersWithUpdatedTime(ctx, corrections)
+	l.adjustClock(leaderDelta)
+	return true
 }
 
 ///////// FASE 1:
@@ -128,20 +294,18 @@ func (l *Leader) StartAlgorithm() {
 // - Seguidores que respondieron correctamente.
 // - Seguidores que no respondieron a tiempo.
 // - Seguidores que tuvieron algún otro problema.
-// La función también registra los resultados y la cantidad de respuestas procesadas.
-func (l *Leader) processFollowers() {
-	// Obtener el tiempo actual del líder en milisegundos (T0)
-	leaderTime := time.Now().UnixMilli() // T0
-
+// La función también registra los resultados y la cantidad de respuestas procesadas. Si se cancela el
+// contexto, no se piden más muestras.
+func (l *Leader) processFollowers(ctx context.Context) {
 	// Obtener la dirección del líder
 	leaderAddr := l.aAbstractNode.Address
 
-	// Registrar la información del líder y el tiempo de la solicitud
-	log.Printf("processFollowers: leaderAddr %s leaderTime %d.", leaderAddr, leaderTime)
-
 	// Lista de direcciones de los seguidores
 	followers := l.aAbstractNode.NodeAddresses
 
+	// Registrar la información del líder; la hora de envío (T1) se anota en cada solicitud
+	log.Printf("processFollowers: leaderAddr %s, %d seguidores.", leaderAddr, len(followers))
+
 	// Crear un canal para recibir los resultados de las respuestas de los seguidores
 	results := make(chan *FollowerInfo, len(followers))
 
@@ -158,7 +322,7 @@ func (l *Leader) processFollowers() {
 			defer wg.Done() // Decrementar el contador del WaitGroup cuando termine este goroutine
 			log.Printf("Enviando solicitud de tiempo a %s (%s).", name, addr)
 			// Enviar la solicitud de tiempo al seguidor y recibir la respuesta en el canal
-			l.sendTimeRequestToFollower(name, addr, leaderTime, leaderAddr, results)
+			l.sendTimeRequestToFollower(ctx, name, addr, leaderAddr, results)
 		}(followerName, followerAddr)
 	}
 
@@ -176,7 +340,7 @@ func (l *Leader) processFollowers() {
 		if res.GetState() == "RESPONDED" {
 			// Seguidor que respondió correctamente
 			l.SuccessfulFollowers[res.GetName()] = res
-			log.Printf("Seguidor %s respondió correctamente con tiempo local: %d ms", res.GetName(), res.GetFollowerTime())
+			log.Printf("Seguidor %s respondió correctamente con tiempo local: %s", res.GetName(), time.Unix(0, res.GetFollowerTime()).String())
 		} else if res.GetState() == "TIMEOUT" {
 			// Seguidor que no respondió a tiempo
 			l.NonRespondingFollowers[res.GetName()] = res
@@ -192,164 +356,478 @@ func (l *Leader) processFollowers() {
 	log.Printf("Proceso de seguidores completado. Respuestas procesadas: %d, Fallos: %d.", len(l.SuccessfulFollowers), len(l.NonRespondingFollowers))
 }
 
-// sendTimeRequestToFollower envía una solicitud de sincronización de tiempo a un seguidor específico.
-// Se crea un mensaje de solicitud en formato JSON que incluye la dirección del líder y el tiempo actual (T0).
-// La función espera la respuesta del seguidor, la procesa y calcula el tiempo de comunicación.
-// Si la respuesta es válida, se obtiene el tiempo local del seguidor y se calcula el tiempo de comunicación.
-// Los resultados se envían al canal de resultados con la información relevante.
-func (l *Leader) sendTimeRequestToFollower(followerName, followerAddr string, leaderTime int64, leaderAddr string, results chan<- *FollowerInfo) {
+// sendTimeRequestToFollower obtiene la muestra de tiempo de un seguidor específico.
+// Como en el método de Cristian, pide SamplesPerFollower muestras y se queda con la de menor tiempo de
+// ida y vuelta (Delay), que es la menos afectada por el ruido de la red. Las muestras cuyo retardo supera
+// MaxRTT se descartan. Si se cancela el contexto no se piden más muestras. El resultado se envía al
+// canal de resultados con la información relevante.
+func (l *Leader) sendTimeRequestToFollower(ctx context.Context, followerName, followerAddr string, leaderAddr string, results chan<- *FollowerInfo) {
+	samples := l.SamplesPerFollower
+	if samples <= 0 {
+		samples = 1
+	}
+
+	var best *FollowerInfo
+	responded, discarded := 0, 0
+	for i := 0; i < samples && ctx.Err() == nil; i++ {
+		sample, err := l.requestTimeSample(ctx, followerName, followerAddr, leaderAddr)
+		if err != nil {
+			log.Printf("Muestra %d/%d de %s fallida: %v", i+1, samples, followerName, err)
+			continue
+		}
+		responded++
 
-	// Crear el mensaje JSON con la solicitud de sincronización de tiempo
-	request := TimeRequest{
-		Message:    "Requesting time sync", // Mensaje de la solicitud
-		Operation:  "GET_TIME",             // Operación que se está solicitando
-		Time:       leaderTime,             // El tiempo actual del líder (T0)
-		LeaderAddr: leaderAddr,             // Dirección del líder
+		// Descartar las muestras cuyo tiempo de ida y vuelta supera el máximo permitido
+		if l.MaxRTT > 0 && time.Duration(sample.Delay) > l.MaxRTT {
+			discarded++
+			log.Printf("Muestra %d/%d de %s descartada: retardo %v mayor que el máximo de %v", i+1, samples, followerName, time.Duration(sample.Delay), l.MaxRTT)
+			continue
+		}
+
+		// Quedarse con la muestra de menor retardo
+		if best == nil || sample.Delay < best.Delay {
+			best = sample
+		}
 	}
 
-	// Serializar el mensaje en formato JSON
-	requestData, err := json.Marshal(request)
-	if err != nil {
-		// Si ocurre un error al serializar, se registra y se envía una respuesta de error al canal
-		log.Printf("Error al serializar la solicitud a JSON: %v", err)
-		results <- NewFollowerInfo(followerAddr, followerName, 0, 0, 0, 0)
+	if best == nil {
+		foll := NewFollowerInfo(followerAddr, followerName, 0, 0, 0, 0)
+		if responded > 0 {
+			// El seguidor respondió, pero ninguna muestra llegó por debajo del máximo de retardo
+			foll.SetState(RTTExceeded)
+		}
+		results <- foll
 		return
 	}
 
-	// Convertir el JSON a un string para su envío
-	requestString := string(requestData)
-	log.Printf("Solicitud enviada a %s: %s", followerAddr, requestString)
+	best.Samples = responded - discarded
+	best.SetState(Responded) // Marcar la respuesta como "RESPONDED"
+	log.Printf("Muestra elegida para %s: retardo %v, diferencia %v (%d muestras válidas de %d)", followerName, time.Duration(best.Delay), time.Duration(best.DiffTime), best.Samples, samples)
+	results <- best
+}
+
+// requestTimeSample envía una solicitud de sincronización de tiempo a un seguidor específico.
+// Sigue el intercambio de cuatro marcas de tiempo de NTP: el líder anota T1 al enviar la solicitud,
+// el seguidor responde con T2 (recepción) y T3 (envío de la respuesta) y el líder anota T4 al recibirla.
+// Con las cuatro marcas FollowerInfo separa la diferencia de reloj del retardo de red, descontando el
+// tiempo que el seguidor tardó en procesar la solicitud.
+// Devuelve la muestra obtenida o un error si el intercambio no se completó o se canceló el contexto.
+func (l *Leader) requestTimeSample(ctx context.Context, followerName, followerAddr string, leaderAddr string) (*FollowerInfo, error) {
+	// Un sub-líder no atiende sondas UDP: su respuesta espera a la ronda de su grupo
+	probe := !l.SubLeaders[followerName]
+	return exchangeTimeSample(ctx, l.aAbstractNode, l.clock, followerName, followerAddr, leaderAddr, l.Rounds, probe)
+}
+
+// exchangeTimeSample realiza un intercambio GET_TIME con el nodo en followerAddr a través del nodo
+// indicado. Lo comparten el líder y los nodos de los modos sin líder. T1 se lee del reloj en
+// nanosegundos y T4 se obtiene sumándole el tiempo transcurrido según la lectura monotónica, de modo
+// que el tiempo de ida y vuelta no se ve afectado por los ajustes del reloj durante el intercambio.
+// Las marcas del seguidor se convierten a nanosegundos según la versión de su respuesta. La petición
+// lleva la ronda indicada, o ninguna si es 0. Con probe, y si el nodo tiene ProbeTimeout, la petición
+// va primero por UDP. Si se cancela el contexto no se hacen más reintentos.
+func exchangeTimeSample(ctx context.Context, node *AbstractNode, clock Clock, followerName, followerAddr string, leaderAddr string, round int, probe bool) (*FollowerInfo, error) {
+	var t1 int64
+	var sent time.Duration
+
+	// Cada intento lleva su propia hora de envío (T1), de modo que un reintento no alarga el retardo medido
+	buildRequest := func(attempt int) (string, error) {
+		// Obtener el tiempo del líder al enviar la solicitud (T1)
+		t1 = clock.Now().UnixNano()
+		sent = clock.Monotonic()
+
+		// Crear el mensaje JSON con la solicitud de sincronización de tiempo
+		request := TimeRequest{
+			Message:    "Requesting time sync", // Mensaje de la solicitud
+			Operation:  "GET_TIME",             // Operación que se está solicitando
+			Time:       t1,                     // El tiempo del líder al enviar la solicitud (T1)
+			LeaderAddr: leaderAddr,             // Dirección del líder
+			Round:      round,                  // Ronda del líder, para que un sub-líder no repita la de su grupo
+			Version:    ProtocolVersion,        // Marcas de tiempo en nanosegundos
+		}
+
+		// Serializar el mensaje en formato JSON
+		requestData, err := json.Marshal(request)
+		if err != nil {
+			// Si ocurre un error al serializar, se registra y se devuelve el error
+			log.Printf("Error al serializar la solicitud a JSON: %v", err)
+			return "", err
+		}
+
+		// Convertir el JSON a un string para su envío
+		requestString := string(requestData)
+		log.Printf("Solicitud enviada a %s (intento %d): %s", followerAddr, attempt, requestString)
+		return requestString, nil
+	}
 
-	// Enviar el mensaje al seguidor y recibir la respuesta
-	reply, err := l.aAbstractNode.SendMessageSync(followerAddr, requestString)
+	// Enviar el mensaje al seguidor y recibir la respuesta, reintentando si no llega a tiempo. Con
+	// ProbeTimeout la petición va primero por UDP y, si se pierden todas las sondas, por el transporte
+	var reply string
+	var attempts int
+	var err error
+	probe = probe && node.ProbeTimeout > 0
+	if probe {
+		reply, attempts, err = node.sendProbe(ctx, followerAddr, buildRequest)
+		if err != nil {
+			log.Printf("Sondas UDP a %s fallidas (%v): se pide el tiempo por el transporte", followerAddr, err)
+		}
+	}
+	if !probe || err != nil {
+		reply, attempts, err = node.sendWithRetries(ctx, followerAddr, buildRequest)
+	}
 	if err != nil {
-		// Si ocurre un error al recibir la respuesta, se registra y se envía un error al canal
+		// Si ocurre un error al recibir la respuesta, se registra y se devuelve el error
 		log.Printf("Error al recibir respuesta de %s: %v", followerAddr, err)
-		followError := NewFollowerInfo(followerAddr, followerName, 0, 0, 0, 0)
-		results <- followError
-		return
+		return nil, err
 	}
 
+	// Obtener el tiempo del líder al recibir la respuesta (T4)
+	t4 := t1 + int64(clock.Monotonic()-sent)
 	// Registrar la respuesta recibida
 	log.Printf("Respuesta recibida de %s: %s", followerAddr, reply)
 
 	// Deserializar la respuesta JSON del seguidor
 	var response map[string]string
 	if err := json.Unmarshal([]byte(reply), &response); err != nil {
-		// Si ocurre un error al procesar la respuesta, se registra y se envía un error al canal
+		// Si ocurre un error al procesar la respuesta, se registra y se devuelve el error
 		log.Printf("Error al procesar la respuesta de %s: %v", followerAddr, err)
-		results <- NewFollowerInfo(followerAddr, followerName, 0, 0, 0, 0)
-		return
+		return nil, err
 	}
 
-	// Calcular el tiempo de comunicación entre el líder y el seguidor
-	endCommTime := time.Now().UnixMilli() // T0 al final de la comunicación
-	timeComm := endCommTime - leaderTime  // Tiempo de comunicación en milisegundos
-	log.Printf("Tiempo de comunicación: %d ms", timeComm)
+	// Obtener las marcas de tiempo del seguidor (T2 y T3) desde la respuesta
+	t2, t3, err := parseFollowerTimes(response)
+	if err != nil {
+		// Si la respuesta no trae marcas de tiempo válidas, se registra y se devuelve el error
+		log.Printf("Respuesta de %s sin marcas de tiempo válidas: %v", followerAddr, err)
+		return nil, err
+	}
+
+	// Un seguidor de la versión 1 responde en milisegundos
+	version := ProtocolVersionMilli
+	if v, err := strconv.Atoi(response["version"]); err == nil {
+		version = v
+	}
+	t2, t3 = fromWire(t2, version), fromWire(t3, version)
+
+	// Devolver la muestra. La diferencia y el retardo se calculan al crear el objeto FollowerInfo:
+	// diff = ((T2 - T1) + (T3 - T4)) / 2 y delay = (T4 - T1) - (T3 - T2)
+	log.Printf("Marcas de tiempo de %s (versión %d): T1 %d, T2 %d, T3 %d, T4 %d ns", followerAddr, version, t1, t2, t3, t4)
+	sample := NewFollowerInfo(followerAddr, followerName, t1, t2, t3, t4)
+	sample.Version = version
+	if corrected, err := strconv.ParseInt(response["corrected"], 10, 64); err == nil && version >= ProtocolVersionNano {
+		sample.Corrected = &corrected
+	}
+	sample.Attempts = attempts
+	return sample, nil
+}
 
-	// Obtener el tiempo local del seguidor desde la respuesta
+// parseFollowerTimes obtiene de la respuesta a GET_TIME la hora de recepción (T2) y de envío (T3) del
+// seguidor. Un seguidor que sólo envía 'localTime' se trata como si hubiese recibido y respondido en el
+// mismo instante (T2 = T3 = localTime).
+func parseFollowerTimes(response map[string]string) (int64, int64, error) {
 	localTimeStr, ok := response["localTime"]
 	if !ok {
-		// Si no se encuentra el campo 'localTime' en la respuesta, se registra y se envía un error
-		log.Printf("No se encontró el campo 'localTime' en la respuesta de %s", followerAddr)
-		results <- NewFollowerInfo(followerAddr, followerName, 0, 0, 0, 0)
-		return
+		return 0, 0, errors.New("no se encontró el campo 'localTime'")
+	}
+	sendTimeStr, ok := response["sendTime"]
+	if !ok {
+		sendTimeStr = localTimeStr
+	}
+	receiveTimeStr, ok := response["receiveTime"]
+	if !ok {
+		receiveTimeStr = sendTimeStr
 	}
 
-	// Convertir el valor de 'localTime' de string a int64
-	followerTime, err := strconv.ParseInt(localTimeStr, 10, 64)
+	t2, err := strconv.ParseInt(receiveTimeStr, 10, 64)
 	if err != nil {
-		// Si ocurre un error al convertir 'localTime', se registra y se envía un error
-		log.Printf("Error al convertir 'localTime' a int64 de %s: %v", followerAddr, err)
-		results <- NewFollowerInfo(followerAddr, followerName, 0, 0, 0, 0)
-		return
+		return 0, 0, fmt.Errorf("error al convertir 'receiveTime' a int64: %w", err)
 	}
-
-	// Enviar los resultados al canal, incluyendo el tiempo local, el tiempo de comunicación y la diferencia entre el lider y el seguidor
-	// La diferncia se calcula al crear el objeto FollowerInfo. diff = (TP + trip_Time) - Now. Trip_time = (Now - T0)/2
-	log.Printf("Tiempo local recibido de %s: %d", followerAddr, followerTime)
-	foll := NewFollowerInfo(followerAddr, followerName, followerTime, leaderTime, timeComm, endCommTime)
-	foll.SetState(Responded) // Marcar la respuesta como "RESPONDED"
-	results <- foll
+	t3, err := strconv.ParseInt(sendTimeStr, 10, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("error al convertir 'sendTime' a int64: %w", err)
+	}
+	return t2, t3, nil
 }
 
 ////////// FASE 2:
 
-// calculateDeltaTimeDifference calcula la diferencia de tiempo (delta) entre el tiempo local del líder
-// y el tiempo ajustado promedio de los seguidores válidos. La función recorre los seguidores exitosos,
-// ajusta sus tiempos en función de sus diferencias de tiempo y calcula una diferencia global promedio (δ).
-// Retorna la diferencia de tiempo calculada (delta) o 0 si no hay seguidores válidos.
-func (l *Leader) calculateDeltaTimeDifference() int64 {
+// calculateDeltaTimeDifference calcula la corrección que debe aplicar cada participante de la ronda.
+// El líder participa como un nodo más con diferencia cero respecto a sí mismo y el tiempo de viaje del
+// seguidor más cercano: su reloj entra en el cálculo igual que el de cualquier seguidor válido.
+// El cálculo es tolerante a fallos, como en la variante de Gusella y Zatti: si OutlierThreshold es
+// mayor que cero sólo cuentan las diferencias del mayor grupo de relojes que distan entre sí como
+// mucho ese umbral, y los seguidores que quedan fuera se marcan como descartados (Rejected). Con las
+// muestras aceptadas, el Aggregator configurado (la media por defecto) obtiene la diferencia objetivo (δ).
+// Siguiendo el algoritmo de Berkeley, cada participante, descartado o no, recibe su propia corrección:
+// la diferencia objetivo menos su diferencia de tiempo (DiffTime). Así un reloj adelantado se retrasa
+// y uno atrasado se adelanta hasta converger todos, líder incluido, en el mismo tiempo acordado.
+// Retorna un mapa nombre del seguidor -> corrección, vacío si no hay seguidores válidos o no se pudo
+// calcular el objetivo, y la corrección que debe aplicar el propio líder.
+func (l *Leader) calculateDeltaTimeDifference() (map[string]int64, int64) {
 	// Log de inicio de la operación de cálculo de la diferencia de tiempo
-	log.Println("Calculando la diferencia de tiempo (delta)")
+	log.Println("Calculando las correcciones de tiempo (delta) de cada participante")
 
-	// Obtener el tiempo actual del líder en milisegundos
-	now := time.Now().UnixMilli()
+	// Mapa con la corrección que se enviará a cada seguidor
+	corrections := make(map[string]int64)
 
-	// Inicializar variables para la suma de los tiempos ajustados y el contador de seguidores válidos
-	var sumTime int64 = 0           // Suma de los tiempos ajustados de los seguidores
-	var validFollowersCount int = 0 // Contador de seguidores con respuestas válidas
+	// Muestras de los participantes; el líder cuenta con diferencia cero respecto a sí mismo
+	leaderName := l.aAbstractNode.Name
+	samples := map[string]*FollowerInfo{
+		leaderName: {Name: leaderName, Address: l.aAbstractNode.Address, State: Responded},
+	}
 
-	// Recorrer los seguidores exitosos y calcular la diferencia de tiempo para cada uno
-	for _, follower := range l.SuccessfulFollowers {
+	// Recorrer los seguidores exitosos y recoger su muestra
+	for name, follower := range l.SuccessfulFollowers {
 		// Verificar si el seguidor tiene un tiempo válido, evitando el valor especial de "Long.MAX_VALUE"
 		if follower.DiffTime != int64(^uint64(0)>>1) { // Long.MAX_VALUE en Java
-			// Ajustar el tiempo del seguidor sumando su diferencia de tiempo al tiempo actual del líder
-			followerTime := now + follower.DiffTime
-			// Sumar el tiempo ajustado del seguidor al total
-			sumTime += followerTime
-			// Incrementar el contador de seguidores válidos
-			validFollowersCount++
+			samples[name] = follower
 		}
 	}
 
-	// Si hay seguidores válidos, calcular la diferencia de tiempo promedio
-	if validFollowersCount > 0 {
-		// Calcular el tiempo ajustado promedio de los seguidores
-		newNow := sumTime / int64(validFollowersCount)
-		// Calcular la diferencia de tiempo (delta) entre el tiempo promedio de los seguidores y el tiempo local del líder
-		delta := newNow - now
+	// Si no hay seguidores válidos, registrar advertencia y retornar el mapa vacío
+	if len(samples) == 1 {
+		log.Println("No se han recibido respuestas válidas de los seguidores.")
+		return corrections, 0
+	}
+
+	// El líder sólo conoce su diferencia con los demás a través de los intercambios: su incertidumbre
+	// es la del mejor de ellos, no cero, para que su intervalo no gane siempre por ser el más estrecho
+	// ni su muestra acapare el peso de las medias ponderadas por el tiempo de viaje
+	samples[leaderName].TripTime = leaderTripTime(samples, leaderName)
+	samples[leaderName].Delay = 2 * samples[leaderName].TripTime
 
-		// Log de los resultados calculados
-		log.Printf("Nuevo tiempo calculado (new_now): %d\n", newNow)
-		log.Printf("Diferencia global (δ): %d\n", delta)
+	// Descartar los relojes que se alejan del grupo mayoritario más que el umbral
+	offsets := make(map[string]int64, len(samples))
+	for name, sample := range samples {
+		offsets[name] = sample.DiffTime
+	}
+	accepted, rejected := filterOutliers(offsets, int64(l.OutlierThreshold), leaderName)
+	for _, name := range rejected {
+		if follower, ok := l.SuccessfulFollowers[name]; ok {
+			follower.SetState(Rejected)
+			l.RejectedFollowers[name] = follower
+			log.Printf("Seguidor %s descartado de la media: diferencia %v fuera del umbral de %v", name, time.Duration(follower.DiffTime), l.OutlierThreshold)
+		} else {
+			log.Printf("El reloj del líder %s queda fuera del umbral de %v y no entra en la media", name, l.OutlierThreshold)
+		}
+	}
+	acceptedSamples := make(map[string]*FollowerInfo, len(accepted))
+	for name := range accepted {
+		acceptedSamples[name] = samples[name]
+	}
 
-		// Retornar la diferencia de tiempo (delta)
-		return delta
+	// Calcular la diferencia objetivo (δ) de los relojes aceptados respecto al líder
+	aggregator := l.Aggregator
+	if aggregator == nil {
+		aggregator = MeanAggregator{}
+	}
+	var target int64
+	var err error
+	if intervalAggregator, ok := aggregator.(IntervalAggregator); ok {
+		target, err = l.aggregateInterval(intervalAggregator, acceptedSamples)
 	} else {
-		// Si no hay seguidores válidos, registrar advertencia y retornar 0
-		log.Println("No se han recibido respuestas válidas de los seguidores.")
+		target, err = aggregator.Aggregate(acceptedSamples)
+	}
+	if err != nil {
+		log.Printf("Error al calcular la diferencia objetivo: %v", err)
+		return corrections, 0
+	}
+	log.Printf("Diferencia objetivo (δ) con %d de %d participantes: %v\n", len(acceptedSamples), len(samples), time.Duration(target))
+
+	// Cada seguidor recibe el objetivo menos su propia diferencia
+	for name, sample := range samples {
+		if name == leaderName {
+			continue
+		}
+		corrections[name] = target - sample.DiffTime
+		log.Printf("Corrección para el seguidor %s: %v (diferencia %v)\n", name, time.Duration(corrections[name]), time.Duration(sample.DiffTime))
+	}
+
+	// El líder, con diferencia cero, se corrige con el propio objetivo
+	log.Printf("Corrección para el líder %s: %v\n", leaderName, time.Duration(target))
+
+	// Retornar las correcciones de cada seguidor y la del líder
+	return corrections, target
+}
+
+// leaderTripTime devuelve el menor tiempo de viaje de las muestras de los seguidores.
+func leaderTripTime(samples map[string]*FollowerInfo, leaderName string) int64 {
+	best := int64(-1)
+	for name, sample := range samples {
+		if name == leaderName || sample.TripTime < 0 {
+			continue
+		}
+		if best < 0 || sample.TripTime < best {
+			best = sample.TripTime
+		}
+	}
+	if best < 0 {
 		return 0
 	}
+	return best
+}
+
+// aggregateInterval calcula el intervalo acordado de la ronda, lo guarda en Agreed y marca como
+// Falseticker a los seguidores cuyo intervalo queda fuera de él. Devuelve el centro del intervalo.
+func (l *Leader) aggregateInterval(aggregator IntervalAggregator, samples map[string]*FollowerInfo) (int64, error) {
+	interval, err := aggregator.AggregateInterval(samples)
+	if err != nil {
+		return 0, err
+	}
+	l.Agreed = &interval
+	log.Printf("Intervalo acordado: %v", interval)
+
+	for _, name := range interval.Falsetickers {
+		if follower, ok := l.SuccessfulFollowers[name]; ok {
+			follower.SetState(Falseticker)
+			l.RejectedFollowers[name] = follower
+			log.Printf("Seguidor %s marcado como falseticker: %v ± %v no contiene el intervalo acordado", name, time.Duration(follower.DiffTime), time.Duration(follower.TripTime))
+		} else {
+			log.Printf("El reloj del líder %s queda fuera del intervalo acordado", name)
+		}
+	}
+	return interval.Offset, nil
+}
+
+// filterOutliers separa las diferencias de tiempo en aceptadas y descartadas. Busca el mayor grupo de
+// diferencias cuya separación máxima no supera el umbral (threshold) y descarta el resto. Si varios
+// grupos empatan en tamaño gana el único que contiene a preferred (el propio líder); si no hay uno solo,
+// no hay mayoría clara y se aceptan todas. Con un umbral menor o igual que cero se aceptan todas.
+func filterOutliers(offsets map[string]int64, threshold int64, preferred string) (map[string]int64, []string) {
+	if threshold <= 0 {
+		return offsets, nil
+	}
+
+	// Ordenar los nombres por diferencia para recorrer las ventanas de valores consecutivos
+	names := make([]string, 0, len(offsets))
+	for name := range offsets {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if offsets[names[i]] == offsets[names[j]] {
+			return names[i] < names[j]
+		}
+		return offsets[names[i]] < offsets[names[j]]
+	})
+
+	// Ventana deslizante: guarda el inicio de cada ventana de tamaño máximo, indexado por su final
+	best := 0
+	starts := make(map[int]int)
+	start := 0
+	for end := range names {
+		for offsets[names[end]]-offsets[names[start]] > threshold {
+			start++
+		}
+		size := end + 1 - start
+		if size > best {
+			best = size
+			starts = make(map[int]int)
+		}
+		if size == best {
+			starts[end+1] = start
+		}
+	}
+
+	// Deshacer el empate con la ventana que contiene a preferred, si es una sola
+	bestStart, bestEnd := 0, len(names)
+	if len(starts) == 1 {
+		for end, start := range starts {
+			bestStart, bestEnd = start, end
+		}
+	} else {
+		candidates := 0
+		for end, start := range starts {
+			for i := start; i < end; i++ {
+				if names[i] == preferred {
+					candidates++
+					bestStart, bestEnd = start, end
+					break
+				}
+			}
+		}
+		if candidates != 1 {
+			bestStart, bestEnd = 0, len(names)
+		}
+	}
+
+	accepted := make(map[string]int64)
+	var rejected []string
+	for i, name := range names {
+		if i >= bestStart && i < bestEnd {
+			accepted[name] = offsets[name]
+		} else {
+			rejected = append(rejected, name)
+		}
+	}
+	return accepted, rejected
+}
+
+// adjustClock aplica la corrección calculada para el líder sobre su reloj local ajustable.
+func (l *Leader) adjustClock(delta int64) {
+	before := l.getCurrentTime()
+	l.clock.Adjust(time.Duration(delta))
+	l.ClockOffset += time.Duration(delta)
+	l.LeaderDelta = time.Duration(delta)
+	log.Printf("Tiempo del líder %s modificado de %s a %s", l.aAbstractNode.Name, time.Unix(0, before).String(), time.Unix(0, l.getCurrentTime()).String())
+}
+
+// getCurrentTime obtiene la hora del reloj local del líder, con las correcciones aplicadas, en nanosegundos desde la época Unix.
+func (l *Leader) getCurrentTime() int64 {
+	return l.clock.Now().UnixNano()
+}
+
+// Clock devuelve el reloj local ajustable del líder.
+func (l *Leader) Clock() *VirtualClock {
+	return l.clock
 }
 
 ////////// FASE 3:
 
-// callFollowersWithUpdatedTime envía una actualización de tiempo a todos los seguidores con el delta de tiempo especificado.
+// callFollowersWithUpdatedTime envía a cada seguidor la corrección de tiempo que le corresponde.
 // La actualización se realiza en paralelo utilizando goroutines para cada seguidor, y las respuestas se procesan conforme
 // van llegando. La función maneja la concurrencia mediante un canal y un WaitGroup para asegurarse de que todas las
-// goroutines terminen antes de procesar los resultados.
-func (l *Leader) callFollowersWithUpdatedTime(delta int64) error {
-	// Log que muestra el inicio de la actualización de tiempo a los seguidores con el delta calculado
-	log.Printf("Enviando actualización de tiempo a los seguidores con delta: %d", delta)
+// goroutines terminen antes de procesar los resultados. Si se cancela el contexto, las correcciones que no
+// hayan llegado dejan de reintentarse y sus seguidores quedan como fallidos.
+func (l *Leader) callFollowersWithUpdatedTime(ctx context.Context, corrections map[string]int64) error {
+	// Log que muestra el inicio de la actualización de tiempo a los seguidores con las correcciones calculadas
+	log.Printf("Enviando actualización de tiempo a los seguidores con correcciones: %v", corrections)
+
+	// Cada corrección tiene un identificador que se mantiene si hay que reenviarla
+	ids := make(map[string]string, len(corrections))
+	for name := range corrections {
+		ids[name] = l.nextUpdateID(name)
+	}
 
-	// Crear un canal para gestionar las respuestas de los seguidores, con un buffer del tamaño del número de seguidores exitosos
-	ch := make(chan *FollowerInfo, len(l.SuccessfulFollowers))
+	// Con canal de difusión, las correcciones van en un único lote y sólo las que no se confirman se
+	// envían después una a una
+	confirmed, corrections := l.broadcastCorrections(ctx, corrections, ids)
+	for name, followerInfo := range confirmed {
+		log.Printf("El seguidor %s confirmó la corrección difundida.", name)
+		l.TimeUpdatedFollowers[name] = followerInfo
+		l.registerAppliedCorrection(followerInfo)
+	}
+
+	// Crear un canal para gestionar las respuestas de los seguidores, con un buffer del tamaño del número de correcciones
+	ch := make(chan *FollowerInfo, len(corrections))
 
 	// Crear un WaitGroup para esperar a que todas las goroutines terminen su ejecución
 	var wg sync.WaitGroup
 
-	// Enviar las tareas en paralelo para cada seguidor exitoso
-	for _, follower := range l.SuccessfulFollowers {
+	// Enviar las tareas en paralelo para cada seguidor con corrección
+	for name, delta := range corrections {
+		follower, ok := l.SuccessfulFollowers[name]
+		if !ok {
+			log.Printf("No hay información del seguidor %s, se omite su corrección.", name)
+			continue
+		}
 		wg.Add(1) // Incrementamos el contador del WaitGroup antes de iniciar cada goroutine
 		// Goroutine para enviar la actualización de tiempo a un seguidor específico
-		go func(follower FollowerInfo) {
+		go func(follower FollowerInfo, delta int64, id string) {
 			defer wg.Done() // Decrementamos el contador del WaitGroup cuando la goroutine termina
 			// Enviar la actualización de tiempo al seguidor y obtener la respuesta
-			followerInfo := l.sendTimeUpdateToFollower(&follower, delta)
+			followerInfo := l.sendTimeUpdateToFollower(ctx, &follower, delta, id)
 			// Enviar la respuesta al canal para su posterior procesamiento
 			ch <- followerInfo
-		}(*follower) // Llamamos a la goroutine pasando el valor de 'follower'
+		}(*follower, delta, ids[name]) // Llamamos a la goroutine pasando el valor de 'follower', su corrección y su identificador
 	}
 
 	// Iniciar una goroutine para cerrar el canal una vez que todas las goroutines hayan terminado
@@ -365,6 +843,7 @@ func (l *Leader) callFollowersWithUpdatedTime(delta int64) error {
 			log.Printf("El seguidor %s respondió correctamente al cambio del timer.", followerInfo.Name)
 			// Guardamos el seguidor como actualizado correctamente en la lista de seguidores actualizados
 			l.TimeUpdatedFollowers[followerInfo.Name] = followerInfo
+			l.registerAppliedCorrection(followerInfo)
 		} else {
 			// Si el seguidor no respondió correctamente, lo agregamos a la lista de seguidores fallidos
 			log.Printf("El seguidor %s no respondió al cambio de su timer.", followerInfo.Name)
@@ -376,17 +855,23 @@ func (l *Leader) callFollowersWithUpdatedTime(delta int64) error {
 	return nil
 }
 
-// sendTimeUpdateToFollower envía una solicitud para actualizar el tiempo del seguidor con un delta especificado.
-// La solicitud es serializada a formato JSON y enviada de forma sincrónica al seguidor. Luego, se procesa la respuesta
-// y se actualiza el estado del seguidor según el resultado. Si hay algún error en el proceso, se registra y se devuelve
-// un seguidor con un estado de error.
-func (l *Leader) sendTimeUpdateToFollower(follower *FollowerInfo, delta int64) *FollowerInfo {
+// sendTimeUpdateToFollower envía una solicitud para actualizar el tiempo del seguidor con un delta especificado
+// y el identificador de la corrección. La solicitud es serializada a formato JSON y enviada de forma
+// sincrónica al seguidor. Luego, se procesa la respuesta y se actualiza el estado del seguidor según el
+// resultado. Si hay algún error en el proceso, se registra y se devuelve un seguidor con un estado de error.
+func (l *Leader) sendTimeUpdateToFollower(ctx context.Context, follower *FollowerInfo, delta int64, id string) *FollowerInfo {
 	// Crear el mapa con la solicitud para modificar el tiempo del sistema del seguidor
+	// La corrección va en la unidad de la versión con la que respondió el seguidor
 	request := DeltaRequest{
 		Message:    "Modifica el tiempo del sistema de tu servidor con el diferencial.",
 		Operation:  "UPDATE_TIME",
-		Delta:      delta,
+		Delta:      toWire(delta, follower.Version),
+		Rate:       follower.RateCorrection,
 		LeaderAddr: l.aAbstractNode.Address,
+		ID:         id,
+	}
+	if follower.Version >= ProtocolVersionNano {
+		request.Version = follower.Version
 	}
 
 	// Serializar la solicitud a JSON
@@ -403,8 +888,9 @@ func (l *Leader) sendTimeUpdateToFollower(follower *FollowerInfo, delta int64) *
 	requestString := string(jsonRequest)
 	log.Printf("Solicitud enviada a %s: %s", follower.GetAddress(), requestString)
 
-	// Enviar la solicitud de manera sincrónica y esperar la respuesta
-	reply, err := l.aAbstractNode.SendMessageSync(follower.GetAddress(), requestString)
+	// Enviar la solicitud de manera sincrónica y esperar la respuesta. Los reintentos llevan el mismo
+	// identificador, de modo que el seguidor no aplica dos veces la corrección si se perdió su respuesta
+	reply, attempts, err := l.aAbstractNode.SendMessageSyncAttempts(ctx, follower.GetAddress(), requestString)
 	if err != nil {
 		// Si hay un error al recibir la respuesta, se registra el error y se crea un nuevo objeto de seguidor con estado de error
 		log.Printf("Error al recibir respuesta de %s: %v", follower.GetAddress(), err)
@@ -425,10 +911,17 @@ func (l *Leader) sendTimeUpdateToFollower(follower *FollowerInfo, delta int64) *
 		return follower
 	}
 
-	// Registrar la respuesta exitosa del seguidor
+	// Comprobar que el seguidor pudo aplicar la corrección
 	followerName := response["followerName"]
 	operation := response["operation"]
-	log.Printf("Respuesta de %s: Operación %s exitosa", followerName, operation)
+	if operation != "OK_MOD_TIME" {
+		log.Printf("El seguidor %s no pudo aplicar la corrección: %s", followerName, response["error"])
+		follower.State = TimeErrorSentUpdate
+		return follower
+	}
+
+	// Registrar la respuesta exitosa del seguidor
+	log.Printf("Respuesta de %s: Operación %s exitosa en %d intento(s)", followerName, operation, attempts)
 
 	// Modificamos el seguidor con la información del delta que se uso para actualizar la hora local del seguidor y su estado
 	follwerUpdate := follower
@@ -439,58 +932,31 @@ func (l *Leader) sendTimeUpdateToFollower(follower *FollowerInfo, delta int64) *
 	return follwerUpdate
 }
 
-////////// FASE 4:
-
-// sendCloseMessagesToFollowers envía un mensaje de cierre a todos los seguidores que han sido actualizados correctamente.
-// Utiliza goroutines para enviar los mensajes de forma concurrente y espera que todas las goroutines terminen antes de
-// finalizar el proceso. Los resultados de las operaciones son procesados a medida que van llegando y se registran.
-func (l *Leader) sendCloseMessagesToFollowers() {
-	// Crear un WaitGroup para esperar a que todas las goroutines terminen
-	var wg sync.WaitGroup
-
-	// Canal para recibir los resultados de las goroutines
-	resultCh := make(chan string, len(l.TimeUpdatedFollowers))
-
-	// Enviar mensaje de cierre a cada seguidor de manera concurrente
-	for _, follower := range l.TimeUpdatedFollowers {
-		wg.Add(1) // Incrementamos el contador del WaitGroup para cada goroutine
-		go func(follower FollowerInfo) {
-			defer wg.Done() // Decrementamos el contador cuando la goroutine termine
-
-			// Enviar el mensaje de cierre al seguidor
-			err := l.sendCloseMessage(&follower)
-			if err != nil {
-				// Si hay un error al enviar el mensaje, se envía un resultado con el error al canal
-				resultCh <- fmt.Sprintf("Error al enviar mensaje de cierre a %s: %s", follower.Name, err)
-				return
-			}
-
-			// Si el mensaje fue enviado correctamente, se simula el éxito y se envía al canal
-			resultCh <- fmt.Sprintf("Mensaje de cierre enviado con éxito a %s", follower.Name)
-		}(*follower) // Llamamos a la goroutine con la información del seguidor
-	}
-
-	// Iniciar una goroutine para esperar que todas las goroutines terminen y cerrar el canal de resultados
-	go func() {
-		wg.Wait()       // Esperamos a que todas las goroutines terminen
-		close(resultCh) // Cerramos el canal cuando se haya completado el procesamiento
-	}()
-
-	// Procesar los resultados a medida que vayan llegando
-	for result := range resultCh {
-		// Registrar cada resultado recibido del canal
-		log.Println(result)
-	}
+// nextUpdateID devuelve un identificador único para la siguiente corrección enviada al seguidor.
+func (l *Leader) nextUpdateID(followerName string) string {
+	seq := atomic.AddUint64(&l.updateSeq, 1)
+	return fmt.Sprintf("%s/%d/%s/%d", l.aAbstractNode.Address, l.Rounds, followerName, seq)
 }
 
-// SendCloseMessage envía un mensaje de cierre a un seguidor.
-// Simulación de la función para enviar mensaje de cierre a un seguidor
-func (l *Leader) sendCloseMessage(follower *FollowerInfo) *FollowerInfo {
+// SetRetryPolicy cambia la política de reintentos de las peticiones del líder a sus seguidores.
+func (l *Leader) SetRetryPolicy(policy RetryPolicy) {
+	l.aAbstractNode.RetryPolicy = policy
+}
 
-	followerAddress := follower.GetAddress()
+// SetProbeTimeout hace que el líder pida el tiempo a sus seguidores con sondas UDP que esperan la
+// respuesta como mucho timeout. Los seguidores deben atender las sondas (Follower.UDPProbes); a los
+// SubLeaders se les pide por el transporte. Con 0 las peticiones de tiempo vuelven a ir por el
+// transporte.
+func (l *Leader) SetProbeTimeout(timeout time.Duration) {
+	l.aAbstractNode.ProbeTimeout = timeout
+}
 
-	followerName := follower.GetName()
+////////// FASE 4:
 
+// sendCloseMessagesToFollowers envía un mensaje de cierre a todos los seguidores indicados.
+// Las peticiones salen a la vez por sockets DEALER, sin esperar cada respuesta antes de enviar la
+// siguiente, y las respuestas se procesan y se registran a medida que se recogen.
+func (l *Leader) sendCloseMessagesToFollowers(followers map[string]*FollowerInfo) {
 	request := closeRequest{
 		Message:    "Cerrar conexión",
 		Operation:  "CLOSE",
@@ -500,58 +966,53 @@ func (l *Leader) sendCloseMessage(follower *FollowerInfo) *FollowerInfo {
 	// Convertir el mensaje a JSON
 	jsonRequest, err := json.Marshal(request)
 	if err != nil {
-		wrappedErr := fmt.Errorf("Error during some operation: %w", err)
-		log.Printf("An error occurred: %v", wrappedErr) // Logging the wrapped error
-		followError := follower
-		followError.State = ErrorClose
-		return followError
+		log.Printf("Error al serializar el mensaje de cierre: %v", err)
+		return
 	}
-
-	// Simulamos el envío del mensaje al seguidor (usando un canal)
-	log.Printf("Mensaje de cierre enviado a %s: %s", followerAddress, jsonRequest)
-	// Serializar la solicitud a JSON
-
 	requestString := string(jsonRequest)
-	log.Printf("Solicitud enviada a %s: %s", followerAddress, requestString)
-	// Enviar la solicitud
-	reply, err := l.aAbstractNode.SendMessageSync(followerAddress, requestString)
-	if err != nil {
-		log.Printf("Error al recibir respuesta de %s: %v", followerAddress, err)
-		followError := follower
-		followError.State = ErrorClose
-		return followError
+
+	// Enviar el mensaje de cierre a cada seguidor sin esperar su respuesta
+	pending := make(map[*FollowerInfo]<-chan AsyncReply, len(followers))
+	for _, follower := range followers {
+		log.Printf("Mensaje de cierre enviado a %s: %s", follower.GetAddress(), requestString)
+		pending[follower] = l.aAbstractNode.SendRequestAsync(follower.GetAddress(), requestString)
 	}
 
-	var response map[string]string
-	err = json.Unmarshal([]byte(reply), &response)
-	if err != nil {
-		log.Printf("Error al deserializar la respuesta del seguidor %s: %v", followerName, err)
-		followError := follower
-		followError.State = ErrorClose
-		return followError
+	// Procesar las respuestas de cada seguidor
+	for follower, replies := range pending {
+		closed := l.closeReplyState(*follower, <-replies)
+		if closed.State != OkClose {
+			log.Printf("Error al enviar mensaje de cierre a %s: %s", follower.Name, closed.State)
+			continue
+		}
+		log.Printf("Mensaje de cierre enviado con éxito a %s", follower.Name)
 	}
-	// Simulamos una respuesta (en un caso real recibirías la respuesta desde el socket)
-	// Aquí se simula un éxito.
-	response = map[string]string{
-		"followerName": followerAddress,
-		"operation":    "CLOSE",
+}
+
+// closeReplyState comprueba la respuesta de un seguidor al mensaje de cierre y devuelve una copia de su
+// información con el estado OkClose si confirmó el cierre o ErrorClose si no.
+func (l *Leader) closeReplyState(follower FollowerInfo, reply AsyncReply) *FollowerInfo {
+	if reply.Err != nil {
+		log.Printf("Error al recibir respuesta de %s: %v", reply.Address, reply.Err)
+		follower.State = ErrorClose
+		return &follower
 	}
 
-	// Convertimos la respuesta a JSON
-	jsonResponse, err := json.Marshal(response)
-	if err != nil {
-		wrappedErr := fmt.Errorf("Error during some operation: %w", err)
-		log.Printf("An error occurred: %v", wrappedErr) // Logging the wrapped error
-		followError := follower
-		followError.State = ErrorClose
-		return followError
+	var response map[string]string
+	if err := json.Unmarshal([]byte(reply.Reply), &response); err != nil {
+		log.Printf("Error al deserializar la respuesta del seguidor %s: %v", follower.Name, err)
+		follower.State = ErrorClose
+		return &follower
+	}
+	if response["operation"] != "CLOSE" {
+		log.Printf("El seguidor %s no confirmó el cierre: %s", follower.Name, reply.Reply)
+		follower.State = ErrorClose
+		return &follower
 	}
 
-	// Simulamos la recepción de la respuesta (en un caso real, recibirías el mensaje desde el socket)
-	log.Printf("Respuesta recibida de %s: %s", followerAddress, jsonResponse)
-	follow := follower
-	follow.State = OkClose
-	return follow
+	log.Printf("Respuesta recibida de %s: %s", reply.Address, reply.Reply)
+	follower.State = OkClose
+	return &follower
 }
 
 ////////// FASE 5:
@@ -597,6 +1058,16 @@ func (l *Leader) printResults() {
 		}
 	}
 
+	// Mostrar seguidores descartados de la media por alejarse del resto
+	l.Logger.Println("\n\t🚫\tSeguidores descartados de la media (fuera del umbral o del intervalo acordado):")
+	if len(l.RejectedFollowers) == 0 {
+		l.Logger.Println("\t\t\tNingún seguidor fue descartado.")
+	} else {
+		for key, value := range l.RejectedFollowers {
+			l.Logger.Printf("\t\t\t- %s:\t%v\n", key, value)
+		}
+	}
+
 	// Mostrar seguidores que actualizaron su tiempo correctamente
 	l.Logger.Println("\n\t🕰️\tSeguidores que actualizaron su tiempo correctamente:")
 	if len(l.TimeUpdatedFollowers) == 0 {
@@ -607,6 +1078,14 @@ func (l *Leader) printResults() {
 		}
 	}
 
+	// Mostrar el intervalo acordado y su incertidumbre
+	if l.Agreed != nil {
+		l.Logger.Printf("\n\t🎯\tDiferencia acordada: %v\n", *l.Agreed)
+	}
+
+	// Mostrar la corrección aplicada por el propio líder
+	l.Logger.Printf("\n\t🧭\tCorrección aplicada por el líder %s: %v (acumulada: %v)\n", l.aAbstractNode.Name, l.LeaderDelta, l.ClockOffset)
+
 	// Mostrar seguidores que no pudieron actualizar su tiempo
 	l.Logger.Println("\n\t❌\tSeguidores que no pudieron actualizar su tiempo:")
 	if len(l.FailedFollowers) == 0 {
@@ -627,7 +1106,83 @@ func (l *Leader) Close() {
 	l.aAbstractNode.Close()
 }
 
-// HandleProcess implements Handler.
+// HandleProcess implementa Handler: el líder recibe las confirmaciones de las correcciones difundidas
+// y los anuncios de otros líderes. No bloquea el mutex del líder, que la ronda en curso mantiene
+// mientras espera las confirmaciones.
 func (l *Leader) HandleProcess(message string) (string, error) {
-	panic("unimplemented")
+	var data map[string]interface{}
+	if err := json.Unmarshal([]byte(message), &data); err != nil {
+		log.Printf("Error al deserializar el mensaje JSON: %v", err)
+		return `{"error":"Error al procesar el mensaje JSON"}`, nil
+	}
+	operation, _ := data["operation"].(string)
+
+	switch operation {
+	case "ACK_UPDATE":
+		return l.handleAck(data), nil
+	case "COORDINATOR":
+		return l.handleCoordinator(data), nil
+	default:
+		log.Printf("Operación no reconocida en el líder: %s", operation)
+		return `{"error":"Operación no reconocida"}`, nil
+	}
+}
+
+// handleCoordinator atiende el anuncio de otro líder. Si el anunciado tiene mayor prioridad, este
+// líder cede el puesto; si no, se anuncia él al otro y a sus seguidores, para que el de menor
+// prioridad sea el que lo ceda.
+func (l *Leader) handleCoordinator(data map[string]interface{}) string {
+	leaderName, _ := data["leader_name"].(string)
+	leaderAddr, _ := data["leader_address"].(string)
+	priority, _ := data["priority"].(float64)
+	name := l.aAbstractNode.Name
+	log.Printf("👑 Operación COORDINATOR en el líder %s: %s (%s) se anuncia como líder", name, leaderName, leaderAddr)
+
+	if outranks(l.Priority, name, int(priority), leaderName) {
+		members := map[string]string{leaderName: leaderAddr}
+		for follower, address := range l.aAbstractNode.NodeAddresses {
+			members[follower] = address
+		}
+		go announceLeader(l.aAbstractNode, Peer{Name: name, Address: l.aAbstractNode.Address, Priority: l.Priority}, members)
+		return fmt.Sprintf(`{"followerName":"%s","operation":"COORDINATOR_IGNORED"}`, name)
+	}
+	l.resign()
+	return fmt.Sprintf(`{"followerName":"%s","operation":"COORDINATOR_OK"}`, name)
+}
+
+// listen empieza a escuchar en la dirección del líder la primera vez que se llama, salvo que sus
+// mensajes le lleguen a través de otro nodo.
+func (l *Leader) listen() error {
+	if l.routed {
+		return nil
+	}
+	l.listenOnce.Do(func() {
+		l.listenErr = l.aAbstractNode.StartListening()
+	})
+	return l.listenErr
+}
+
+// resign hace que el líder deje de ejecutar rondas.
+func (l *Leader) resign() {
+	l.resignOnce.Do(func() { close(l.resigned) })
+}
+
+// HandleAsync implementa AsyncHandler: atiende las confirmaciones de correcciones difundidas y los
+// anuncios de otros líderes que llegan sin esperar respuesta, igual que por el canal de peticiones.
+func (l *Leader) HandleAsync(message string) {
+	var data map[string]interface{}
+	if err := json.Unmarshal([]byte(message), &data); err != nil {
+		log.Printf("Error al deserializar el mensaje asíncrono: %v", err)
+		return
+	}
+	operation, _ := data["operation"].(string)
+
+	switch operation {
+	case "ACK_UPDATE":
+		l.handleAck(data)
+	case "COORDINATOR":
+		l.handleCoordinator(data)
+	default:
+		log.Printf("Operación asíncrona no reconocida en el líder %s: %s", l.aAbstractNode.Name, operation)
+	}
 }
@@ -0,0 +1,128 @@
+package berkeley
+
+import (
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestFilterOutliers(t *testing.T) {
+	tests := []struct {
+		name      string
+		offsets   map[string]int64
+		threshold int64
+		rejected  []string
+	}{
+		{
+			name:      "sin umbral se aceptan todas",
+			offsets:   map[string]int64{"L": 0, "A": 10, "B": 1000},
+			threshold: 0,
+		},
+		{
+			name:      "todas dentro del umbral",
+			offsets:   map[string]int64{"L": 0, "A": 3, "B": -2},
+			threshold: 5,
+		},
+		{
+			name:      "mayoría clara",
+			offsets:   map[string]int64{"L": 0, "A": 1, "B": 2, "C": 100},
+			threshold: 5,
+			rejected:  []string{"C"},
+		},
+		{
+			name:      "varios descartados a ambos lados",
+			offsets:   map[string]int64{"L": 0, "A": 1, "B": 2, "C": 100, "D": -100},
+			threshold: 5,
+			rejected:  []string{"C", "D"},
+		},
+		{
+			name:      "empate resuelto hacia el líder",
+			offsets:   map[string]int64{"L": 0, "A": 3, "B": 100, "C": 103},
+			threshold: 5,
+			rejected:  []string{"B", "C"},
+		},
+		{
+			name:      "un seguidor contra el líder gana el líder",
+			offsets:   map[string]int64{"L": 0, "A": -5000},
+			threshold: 1000,
+			rejected:  []string{"A"},
+		},
+		{
+			name:      "empate con el líder en las dos ventanas",
+			offsets:   map[string]int64{"L": 0, "A": -5, "B": 5},
+			threshold: 5,
+		},
+		{
+			name:      "empate sin el líder en ninguna ventana",
+			offsets:   map[string]int64{"L": 50, "A": 0, "B": 1, "C": 100, "D": 101},
+			threshold: 5,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			accepted, rejected := filterOutliers(tt.offsets, tt.threshold, "L")
+			sort.Strings(rejected)
+			if len(rejected) != len(tt.rejected) {
+				t.Fatalf("descartados %v, se esperaba %v", rejected, tt.rejected)
+			}
+			for i := range rejected {
+				if rejected[i] != tt.rejected[i] {
+					t.Fatalf("descartados %v, se esperaba %v", rejected, tt.rejected)
+				}
+			}
+			if len(accepted)+len(rejected) != len(tt.offsets) {
+				t.Errorf("aceptados %v y descartados %v no cubren %v", accepted, rejected, tt.offsets)
+			}
+		})
+	}
+}
+
+// TestLeaderRoundOverMemoryNetwork ejecuta una ronda completa entre un líder y dos seguidores con relojes
+// manuales desfasados, sobre una red en memoria. Como los relojes no avanzan, los retardos son nulos y
+// el resultado es exacto: todos los relojes acaban en la media de los tres.
+func TestLeaderRoundOverMemoryNetwork(t *testing.T) {
+	factory, err := NewTransportFactory(MemoryTransportName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous := DefaultTransport
+	DefaultTransport = factory
+	defer func() { DefaultTransport = previous }()
+
+	base := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	skews := map[string]time.Duration{"F1": 50 * time.Millisecond, "F2": -30 * time.Millisecond}
+	addresses := map[string]string{"F1": "memory:f1", "F2": "memory:f2"}
+
+	followers := make(map[string]*Follower)
+	for name, skew := range skews {
+		follower, err := NewFollowerWithClock(name, addresses[name], "memory:leader", 1000, NewManualClock(base.Add(skew)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := follower.StartAlgorithm(); err != nil {
+			t.Fatal(err)
+		}
+		defer follower.aAbstractNode.Close()
+		followers[name] = follower
+	}
+
+	leader, err := InitializeLeaderNodeWithClock("L", "memory:leader", 1000, addresses, NewManualClock(base))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer leader.Close()
+	leader.StartAlgorithm()
+
+	if len(leader.TimeUpdatedFollowers) != len(followers) {
+		t.Fatalf("seguidores actualizados: %v", leader.TimeUpdatedFollowers)
+	}
+	agreed := leader.Clock().Now()
+	if want := base.Add(20 * time.Millisecond / 3); !agreed.Equal(want) {
+		t.Errorf("hora del líder %v, se esperaba %v", agreed, want)
+	}
+	for name, follower := range followers {
+		if got := follower.CurrentTime(); !got.Equal(agreed) {
+			t.Errorf("hora de %s %v, se esperaba %v", name, got, agreed)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package berkeley
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// AgreedInterval es el intervalo de diferencias de tiempo en el que coinciden más fuentes en una ronda.
+type AgreedInterval struct {
+	Offset       int64    // Diferencia acordada respecto al líder en nanosegundos: el centro del intervalo
+	Uncertainty  int64    // Semiancho del intervalo en nanosegundos: la diferencia real está en Offset ± Uncertainty
+	Sources      []string // Fuentes cuyo intervalo contiene al acordado
+	Falsetickers []string // Fuentes cuyo intervalo no contiene al acordado
+}
+
+// String devuelve el intervalo acordado en formato legible.
+func (i AgreedInterval) String() string {
+	return fmt.Sprintf("%v ± %v (%d fuentes de acuerdo, %d falsetickers)", time.Duration(i.Offset), time.Duration(i.Uncertainty), len(i.Sources), len(i.Falsetickers))
+}
+
+// IntervalAggregator es un Aggregator que, además de la diferencia objetivo, calcula el intervalo en
+// el que coinciden las fuentes. Si el Aggregator del líder lo implementa, el líder informa del
+// intervalo acordado y marca como Falseticker las fuentes que quedan fuera de él.
+type IntervalAggregator interface {
+	Aggregator
+	AggregateInterval(samples map[string]*FollowerInfo) (AgreedInterval, error)
+}
+
+// MarzulloAggregator trata cada muestra como el intervalo DiffTime ± TripTime, en el que tiene que
+// estar la diferencia real si el retardo de ida y el de vuelta no son negativos, y aplica el algoritmo
+// de Marzullo: busca el intervalo más pequeño en el que coincide el mayor número de fuentes.
+type MarzulloAggregator struct{}
+
+// marzulloEdge es un extremo del intervalo de una fuente en el algoritmo de Marzullo.
+type marzulloEdge struct {
+	value int64
+	start bool // Extremo inferior (true) o superior (false)
+}
+
+// Aggregate implementa Aggregator con el centro del intervalo acordado.
+func (a MarzulloAggregator) Aggregate(samples map[string]*FollowerInfo) (int64, error) {
+	interval, err := a.AggregateInterval(samples)
+	if err != nil {
+		return 0, err
+	}
+	return interval.Offset, nil
+}
+
+// AggregateInterval implementa IntervalAggregator.
+func (MarzulloAggregator) AggregateInterval(samples map[string]*FollowerInfo) (AgreedInterval, error) {
+	if len(samples) == 0 {
+		return AgreedInterval{}, errNoSamples
+	}
+
+	edges := make([]marzulloEdge, 0, 2*len(samples))
+	for _, sample := range samples {
+		low, high := sampleInterval(sample)
+		edges = append(edges, marzulloEdge{value: low, start: true}, marzulloEdge{value: high, start: false})
+	}
+	// A igual valor los extremos inferiores van antes, para que dos intervalos que se tocan coincidan
+	sort.Slice(edges, func(i, j int) bool {
+		if edges[i].value != edges[j].value {
+			return edges[i].value < edges[j].value
+		}
+		return edges[i].start && !edges[j].start
+	})
+
+	// Recorrer los extremos contando cuántos intervalos se solapan en cada tramo
+	best, count := 0, 0
+	var low, high int64
+	for i, edge := range edges {
+		if !edge.start {
+			count--
+			continue
+		}
+		count++
+		// El tramo termina en el siguiente extremo, que siempre existe tras uno inferior
+		next := edges[i+1].value
+		if count > best || (count == best && next-edge.value < high-low) {
+			best, low, high = count, edge.value, next
+		}
+	}
+
+	interval := AgreedInterval{
+		Offset:      low + (high-low)/2,
+		Uncertainty: (high - low) / 2,
+	}
+	for name, sample := range samples {
+		sampleLow, sampleHigh := sampleInterval(sample)
+		if sampleLow <= low && sampleHigh >= high {
+			interval.Sources = append(interval.Sources, name)
+		} else {
+			interval.Falsetickers = append(interval.Falsetickers, name)
+		}
+	}
+	sort.Strings(interval.Sources)
+	sort.Strings(interval.Falsetickers)
+	return interval, nil
+}
+
+// sampleInterval devuelve el intervalo DiffTime ± TripTime de una muestra.
+func sampleInterval(sample *FollowerInfo) (int64, int64) {
+	tripTime := sample.TripTime
+	if tripTime < 0 {
+		tripTime = 0
+	}
+	return sample.DiffTime - tripTime, sample.DiffTime + tripTime
+}
@@ -0,0 +1,60 @@
+package berkeley
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMarzulloAggregateInterval(t *testing.T) {
+	tests := []struct {
+		name    string
+		samples map[string]*FollowerInfo
+		want    AgreedInterval
+	}{
+		{
+			name:    "una sola fuente",
+			samples: map[string]*FollowerInfo{"A": {DiffTime: 0, TripTime: 3}},
+			want:    AgreedInterval{Offset: 0, Uncertainty: 3, Sources: []string{"A"}},
+		},
+		{
+			name: "dos fuentes de acuerdo y un falseticker",
+			samples: map[string]*FollowerInfo{
+				"A": {DiffTime: 10, TripTime: 5},
+				"B": {DiffTime: 12, TripTime: 5},
+				"C": {DiffTime: 40, TripTime: 2},
+			},
+			want: AgreedInterval{Offset: 11, Uncertainty: 4, Sources: []string{"A", "B"}, Falsetickers: []string{"C"}},
+		},
+		{
+			name: "a igual número de fuentes gana el intervalo más estrecho",
+			samples: map[string]*FollowerInfo{
+				"A": {DiffTime: 5, TripTime: 5},
+				"B": {DiffTime: 21, TripTime: 1},
+			},
+			want: AgreedInterval{Offset: 21, Uncertainty: 1, Sources: []string{"B"}, Falsetickers: []string{"A"}},
+		},
+		{
+			name: "intervalos que se tocan coinciden",
+			samples: map[string]*FollowerInfo{
+				"A": {DiffTime: 0, TripTime: 10},
+				"B": {DiffTime: 20, TripTime: 10},
+			},
+			want: AgreedInterval{Offset: 10, Uncertainty: 0, Sources: []string{"A", "B"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := MarzulloAggregator{}.AggregateInterval(tt.samples)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("intervalo %+v, se esperaba %+v", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := (MarzulloAggregator{}).AggregateInterval(nil); err == nil {
+		t.Error("sin muestras se esperaba un error")
+	}
+}
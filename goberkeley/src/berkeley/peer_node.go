@@ -0,0 +1,198 @@
+package berkeley
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// peerNode reúne lo que comparten los nodos de los modos sin líder (GossipNode y ConvergenceNode):
+// atienden GET_TIME y CLOSE de los demás nodos, ejecutan una ronda cada intervalo y aplican la
+// corrección de la ronda a su reloj corregido. Cada algoritmo aporta sólo su ronda.
+type peerNode struct {
+	aAbstractNode *AbstractNode
+	clock         *VirtualClock // Reloj corregido con los ajustes de cada ronda
+	mu            sync.Mutex    // Evita que dos rondas se solapen
+	kind          string        // Modo del nodo en las trazas, por ejemplo "gossip"
+	Logger        *log.Logger
+
+	// SamplesPerPeer es el número de muestras de tiempo que se piden a cada par; se usa la de menor retardo.
+	SamplesPerPeer int
+	// MaxRTT es el tiempo de ida y vuelta máximo para aceptar una muestra (0 lo desactiva).
+	MaxRTT time.Duration
+	// AdjustMode es la política de aplicación de las correcciones; vacía equivale a StepMode.
+	AdjustMode AdjustMode
+	// MaxSlewRate es la velocidad máxima de la corrección gradual en partes por millón (DefaultMaxSlewRate si es 0).
+	MaxSlewRate float64
+	// Rounds es el número de rondas ejecutadas.
+	Rounds int
+}
+
+// newPeerNode crea la parte común de un nodo sin líder que escucha en address y conoce a los pares
+// indicados (nombre -> dirección). Las correcciones se aplican sobre un VirtualClock construido encima
+// del reloj indicado, o sobre el propio reloj si ya es un VirtualClock.
+func newPeerNode(kind, name, address string, timeout time.Duration, peers map[string]string, clock Clock) (*peerNode, error) {
+	abstractNode, err := InitializeNodeWithAddresses(name, address, timeout, peers)
+	if err != nil {
+		return nil, err
+	}
+
+	node := &peerNode{
+		aAbstractNode: abstractNode,
+		clock:         virtualClockFor(clock),
+		kind:          kind,
+		Logger:        log.Default(),
+	}
+	node.aAbstractNode.Handler = node
+	return node, nil
+}
+
+// HandleProcess implementa Handler: responde a GET_TIME de los pares y a CLOSE.
+func (p *peerNode) HandleProcess(message string) (string, error) {
+	// Anotar la hora de recepción (T2) antes de cualquier otro procesamiento
+	T2 := p.clock.Now().UnixNano()
+	received := p.clock.Monotonic()
+
+	var data map[string]interface{}
+	if err := json.Unmarshal([]byte(message), &data); err != nil {
+		log.Printf("Error al deserializar el mensaje JSON: %v", err)
+		return `{"error":"Error al procesar el mensaje JSON"}`, nil
+	}
+	operation, _ := data["operation"].(string)
+
+	switch operation {
+	case "GET_TIME":
+		T3 := T2 + int64(p.clock.Monotonic()-received)
+		return timeReplyMessage(p.aAbstractNode.Name, p.aAbstractNode.Address, T2, T3, messageVersion(data), nil), nil
+	case "CLOSE":
+		log.Printf("🔌 Operación CLOSE en el nodo %s %s", p.kind, p.aAbstractNode.Name)
+		return fmt.Sprintf(`{"followerName":"%s","operation":"CLOSE"}`, p.aAbstractNode.Name), nil
+	default:
+		log.Printf("Operación no reconocida en el nodo %s %s: %s", p.kind, p.aAbstractNode.Name, operation)
+		return `{"error":"Operación no reconocida"}`, nil
+	}
+}
+
+// StartAlgorithm empieza a atender las peticiones de tiempo de los pares.
+func (p *peerNode) StartAlgorithm() error {
+	log.Printf("Iniciando nodo %s %s en %s", p.kind, p.aAbstractNode.Name, p.aAbstractNode.Address)
+	return p.aAbstractNode.StartListening()
+}
+
+// runPeriodic ejecuta round cada intervalo (interval) hasta que se cancela el contexto.
+func (p *peerNode) runPeriodic(ctx context.Context, interval time.Duration, round func() int64) error {
+	if interval <= 0 {
+		return fmt.Errorf("intervalo de sincronización no válido: %v", interval)
+	}
+	p.Logger.Printf("\n\n\t*************** Iniciando sincronización %s de %s cada %v *****************", p.kind, p.aAbstractNode.Name, interval)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		round()
+
+		select {
+		case <-ctx.Done():
+			log.Printf("Parada del nodo %s %s tras %d rondas", p.kind, p.aAbstractNode.Name, p.Rounds)
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// peers devuelve los demás nodos del grupo.
+func (p *peerNode) peers() map[string]string {
+	peers := make(map[string]string, len(p.aAbstractNode.NodeAddresses))
+	for name, address := range p.aAbstractNode.NodeAddresses {
+		if name != p.aAbstractNode.Name {
+			peers[name] = address
+		}
+	}
+	return peers
+}
+
+// samplePeers pide SamplesPerPeer muestras de tiempo a cada par de manera concurrente y devuelve, por
+// cada uno, la de menor retardo.
+func (p *peerNode) samplePeers(peers map[string]string) []*FollowerInfo {
+	results := make(chan *FollowerInfo, len(peers))
+	var wg sync.WaitGroup
+	for name, address := range peers {
+		wg.Add(1)
+		go func(name, address string) {
+			defer wg.Done()
+			results <- bestTimeSample(p.aAbstractNode, p.clock, name, address, p.SamplesPerPeer, p.MaxRTT)
+		}(name, address)
+	}
+	wg.Wait()
+	close(results)
+
+	samples := make([]*FollowerInfo, 0, len(peers))
+	for sample := range results {
+		samples = append(samples, sample)
+	}
+	return samples
+}
+
+// bestTimeSample pide samples muestras de tiempo al nodo en address y devuelve la de menor retardo, con
+// estado Responded, descartando las que superan maxRTT (0 no descarta ninguna). Si no hay ninguna válida
+// devuelve una muestra vacía con el estado del fallo.
+func bestTimeSample(node *AbstractNode, clock Clock, name, address string, samples int, maxRTT time.Duration) *FollowerInfo {
+	if samples <= 0 {
+		samples = 1
+	}
+
+	var best *FollowerInfo
+	responded, valid := 0, 0
+	for i := 0; i < samples; i++ {
+		sample, err := exchangeTimeSample(context.Background(), node, clock, name, address, node.Address, 0, true)
+		if err != nil {
+			continue
+		}
+		responded++
+		if maxRTT > 0 && time.Duration(sample.Delay) > maxRTT {
+			continue
+		}
+		valid++
+		if best == nil || sample.Delay < best.Delay {
+			best = sample
+		}
+	}
+
+	if best == nil {
+		peer := NewFollowerInfo(address, name, 0, 0, 0, 0)
+		if responded > 0 {
+			peer.SetState(RTTExceeded)
+		}
+		return peer
+	}
+	best.Samples = valid
+	best.SetState(Responded)
+	return best
+}
+
+// adjustClock aplica la corrección de la ronda al reloj corregido según AdjustMode: en SlewMode las
+// correcciones negativas se reparten en el tiempo para que el reloj no retroceda. La corrección se midió
+// con el reloj ya corregido, así que sustituye a la gradual que quedara pendiente.
+func (p *peerNode) adjustClock(delta int64) {
+	correction := time.Duration(delta)
+	if p.AdjustMode == SlewMode && delta < 0 {
+		p.clock.Slew(correction, p.MaxSlewRate)
+		return
+	}
+	p.clock.StopSlew()
+	p.clock.Adjust(correction)
+}
+
+// Clock devuelve el reloj corregido del nodo.
+func (p *peerNode) Clock() *VirtualClock {
+	return p.clock
+}
+
+// Close cierra los recursos del nodo.
+func (p *peerNode) Close() {
+	p.aAbstractNode.Close()
+}
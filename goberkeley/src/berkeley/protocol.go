@@ -0,0 +1,61 @@
+package berkeley
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Versiones del protocolo de sincronización. Las marcas de tiempo y las correcciones viajan en
+// milisegundos en la versión 1 y en nanosegundos en la versión 2. Los mensajes de la versión 1 no
+// llevan el campo "version", de modo que su ausencia identifica a los nodos anteriores.
+const (
+	ProtocolVersionMilli = 1
+	ProtocolVersionNano  = 2
+	// ProtocolVersion es la versión que envían los nodos de este paquete.
+	ProtocolVersion = ProtocolVersionNano
+)
+
+// messageVersion devuelve la versión de un mensaje deserializado: la del campo "version", que puede
+// llegar como número o como texto, o la 1 si no lo tiene.
+func messageVersion(data map[string]interface{}) int {
+	switch version := data["version"].(type) {
+	case float64:
+		return int(version)
+	case string:
+		if v, err := strconv.Atoi(version); err == nil {
+			return v
+		}
+	}
+	return ProtocolVersionMilli
+}
+
+// toWire convierte una marca de tiempo o una corrección en nanosegundos a la unidad de la versión indicada.
+func toWire(nanos int64, version int) int64 {
+	if version >= ProtocolVersionNano {
+		return nanos
+	}
+	return nanos / int64(time.Millisecond)
+}
+
+// fromWire convierte una marca de tiempo o una corrección en la unidad de la versión indicada a nanosegundos.
+func fromWire(value int64, version int) int64 {
+	if version >= ProtocolVersionNano {
+		return value
+	}
+	return value * int64(time.Millisecond)
+}
+
+// wireInt64 lee un entero de un mensaje deserializado. Los campos que llegan como texto se leen sin
+// pérdida; los numéricos pasan por float64 y pierden precisión por encima de 2^53, lo que sólo afecta a
+// las marcas de tiempo en nanosegundos que no se usan en cálculos.
+func wireInt64(data map[string]interface{}, field string) (int64, error) {
+	switch value := data[field].(type) {
+	case float64:
+		return int64(value), nil
+	case string:
+		return strconv.ParseInt(value, 10, 64)
+	default:
+		return 0, fmt.Errorf("no se encontró el campo '%s'", field)
+	}
+}
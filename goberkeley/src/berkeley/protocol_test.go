@@ -0,0 +1,29 @@
+package berkeley
+
+import "testing"
+
+func TestWireConversion(t *testing.T) {
+	tests := []struct {
+		name    string
+		version int
+		nanos   int64
+		wire    int64
+		back    int64 // Nanosegundos al volver a leer el valor: la versión 1 pierde lo que no llega a milisegundo
+	}{
+		{name: "nanosegundos", version: ProtocolVersionNano, nanos: 1_500_000_123, wire: 1_500_000_123, back: 1_500_000_123},
+		{name: "milisegundos", version: ProtocolVersionMilli, nanos: 1_500_000_123, wire: 1500, back: 1_500_000_000},
+		{name: "corrección negativa en milisegundos", version: ProtocolVersionMilli, nanos: -2_700_000, wire: -2, back: -2_000_000},
+		{name: "versión posterior en nanosegundos", version: ProtocolVersionNano + 1, nanos: 42, wire: 42, back: 42},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wire := toWire(tt.nanos, tt.version)
+			if wire != tt.wire {
+				t.Errorf("toWire(%d, %d) = %d, se esperaba %d", tt.nanos, tt.version, wire, tt.wire)
+			}
+			if back := fromWire(wire, tt.version); back != tt.back {
+				t.Errorf("fromWire(%d, %d) = %d, se esperaba %d", wire, tt.version, back, tt.back)
+			}
+		})
+	}
+}
@@ -0,0 +1,93 @@
+package berkeley
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// RetryPolicy es la política de reintentos de los envíos síncronos, según el patrón "Lazy Pirate" de
+// ZeroMQ: si la respuesta no llega a tiempo se descarta la conexión, se espera Backoff y se repite la
+// petición por una nueva, duplicando la espera en cada reintento hasta MaxBackoff.
+// Sólo se deben reintentar peticiones idempotentes o que el destino sepa reconocer como repetidas.
+type RetryPolicy struct {
+	Attempts   int           // Número total de intentos (1 si es 0 o negativo)
+	Backoff    time.Duration // Espera antes del primer reintento
+	MaxBackoff time.Duration // Espera máxima entre reintentos (sin límite si es 0)
+}
+
+// DefaultRetryPolicy es la política de los nodos nuevos: tres intentos con 100 ms de espera inicial,
+// suficiente para que una pérdida breve de paquetes no deje a un seguidor fuera de la ronda.
+var DefaultRetryPolicy = RetryPolicy{Attempts: 3, Backoff: 100 * time.Millisecond, MaxBackoff: time.Second}
+
+// backoff devuelve la espera antes del reintento que sigue al intento attempt (empezando en 1).
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	wait := p.Backoff
+	for i := 1; i < attempt && (p.MaxBackoff <= 0 || wait < p.MaxBackoff); i++ {
+		wait *= 2
+	}
+	if p.MaxBackoff > 0 && wait > p.MaxBackoff {
+		return p.MaxBackoff
+	}
+	return wait
+}
+
+// sendWithRetries realiza un intercambio con la dirección siguiendo la RetryPolicy del nodo. build
+// construye el mensaje de cada intento, para que pueda llevar datos propios del intento como la hora
+// de envío. Devuelve la respuesta, el número de intentos realizados y el error del último intento. Si
+// se cancela el contexto no se hacen más intentos y la espera entre ellos se interrumpe.
+func (n *AbstractNode) sendWithRetries(ctx context.Context, address string, build func(attempt int) (string, error)) (string, int, error) {
+	return n.sendWithPolicy(ctx, address, n.RetryPolicy, build)
+}
+
+// sendWithPolicy es como sendWithRetries, pero con la política indicada en lugar de la del nodo.
+func (n *AbstractNode) sendWithPolicy(ctx context.Context, address string, policy RetryPolicy, build func(attempt int) (string, error)) (string, int, error) {
+	attempts := policy.Attempts
+	if attempts <= 0 {
+		attempts = 1
+	}
+
+	var lastErr error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		if err := ctx.Err(); err != nil {
+			return "", attempt - 1, fmt.Errorf("intercambio con %s cancelado: %w", address, err)
+		}
+		message, err := build(attempt)
+		if err != nil {
+			return "", attempt, err
+		}
+		reply, err := n.Transport.Request(address, message)
+		if err == nil {
+			if attempt > 1 {
+				n.Logger.Printf("Respuesta de %s recibida en el intento %d de %d", address, attempt, attempts)
+			}
+			return reply, attempt, nil
+		}
+		if errors.Is(err, ErrTransportClosed) {
+			return "", attempt, err
+		}
+		lastErr = err
+
+		if attempt < attempts {
+			wait := policy.backoff(attempt)
+			n.Logger.Printf("Intento %d de %d con %s fallido: %v; nuevo intento en %v", attempt, attempts, address, err, wait)
+			if err := sleepContext(ctx, wait); err != nil {
+				return "", attempt, fmt.Errorf("intercambio con %s cancelado tras %d intentos: %w", address, attempt, err)
+			}
+		}
+	}
+	return "", attempts, fmt.Errorf("sin respuesta de %s tras %d intentos: %w", address, attempts, lastErr)
+}
+
+// sleepContext espera el tiempo indicado o hasta que se cancela el contexto, y en ese caso devuelve su error.
+func sleepContext(ctx context.Context, wait time.Duration) error {
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
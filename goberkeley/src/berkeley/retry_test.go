@@ -0,0 +1,47 @@
+package berkeley
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  RetryPolicy
+		attempt int
+		want    time.Duration
+	}{
+		{name: "primer reintento", policy: DefaultRetryPolicy, attempt: 1, want: 100 * time.Millisecond},
+		{name: "se duplica", policy: DefaultRetryPolicy, attempt: 3, want: 400 * time.Millisecond},
+		{name: "se limita a MaxBackoff", policy: DefaultRetryPolicy, attempt: 5, want: time.Second},
+		{name: "sigue limitada", policy: DefaultRetryPolicy, attempt: 40, want: time.Second},
+		{name: "sin límite", policy: RetryPolicy{Backoff: 100 * time.Millisecond}, attempt: 5, want: 1600 * time.Millisecond},
+		{name: "sin espera", policy: RetryPolicy{Attempts: 3}, attempt: 2, want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.policy.backoff(tt.attempt); got != tt.want {
+				t.Errorf("backoff(%d) = %v, se esperaba %v", tt.attempt, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSleepContextCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	start := time.Now()
+	if err := sleepContext(ctx, time.Minute); !errors.Is(err, context.Canceled) {
+		t.Fatalf("sleepContext devolvió %v, se esperaba context.Canceled", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("la espera cancelada duró %v", elapsed)
+	}
+	if err := sleepContext(context.Background(), time.Millisecond); err != nil {
+		t.Errorf("sleepContext devolvió %v, se esperaba nil", err)
+	}
+}
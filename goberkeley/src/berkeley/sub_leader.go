@@ -0,0 +1,218 @@
+package berkeley
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// SubLeader es un nodo intermedio de la sincronización jerárquica. Ante el líder superior actúa como
+// seguidor y ante su grupo como líder: cuando el líder superior le pide la hora, sincroniza primero a
+// su grupo y responde con el tiempo acordado por el grupo; cuando recibe la corrección del líder
+// superior, la suma a la corrección que el grupo había calculado para cada miembro y para sí mismo.
+// El grupo se sincroniza una sola vez por ronda del líder superior: las demás muestras de esa ronda se
+// responden con el mismo resultado, con el que después se aplican las correcciones. El tiempo de espera
+// del líder superior debe cubrir una ronda completa del grupo; por eso el líder no le pide la hora con
+// sondas UDP (Leader.SubLeaders).
+type SubLeader struct {
+	follower *Follower // Lado seguidor: escucha al líder superior
+	group    *Leader   // Lado líder: sincroniza al grupo
+
+	mu          sync.Mutex
+	synced      bool             // El grupo se sincronizó y sus correcciones aún no se han aplicado
+	round       int              // Ronda del líder superior en la que se sincronizó el grupo
+	pending     map[string]int64 // Correcciones del grupo calculadas en la ronda
+	pendingSelf int64            // Corrección del propio sub-líder calculada en la ronda
+}
+
+// NewSubLeader crea un sub-líder que escucha en address al líder superior (leaderAddress) y sincroniza
+// a los miembros de su grupo (groupAddresses, nombre -> dirección). Ambos lados comparten el mismo reloj
+// corregido, construido sobre el reloj indicado.
+func NewSubLeader(name, address, leaderAddress string, timeout time.Duration, groupAddresses map[string]string, clock Clock) (*SubLeader, error) {
+	follower, err := NewFollowerWithClock(name, address, leaderAddress, timeout, clock)
+	if err != nil {
+		return nil, err
+	}
+	group, err := InitializeLeaderNodeWithClock(name, address, timeout, groupAddresses, follower.clock)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &SubLeader{follower: follower, group: group}
+	// Los mensajes del líder superior pasan primero por el sub-líder, y con ellos las confirmaciones de
+	// las correcciones difundidas al grupo
+	follower.aAbstractNode.Handler = s
+	group.routed = true
+	return s, nil
+}
+
+// Follower devuelve el lado seguidor del sub-líder, para configurar cómo aplica sus correcciones.
+func (s *SubLeader) Follower() *Follower {
+	return s.follower
+}
+
+// Group devuelve el lado líder del sub-líder, para configurar cómo sincroniza a su grupo.
+func (s *SubLeader) Group() *Leader {
+	return s.group
+}
+
+// StartAlgorithm enlaza el canal de difusión al grupo, si lo hay, y empieza a escuchar al líder superior.
+func (s *SubLeader) StartAlgorithm() error {
+	log.Printf("Iniciando sub-líder %s en %s con grupo %v", s.follower.aAbstractNode.Name, s.follower.aAbstractNode.Address, s.group.aAbstractNode.NodeAddresses)
+	s.group.broadcaster()
+	return s.follower.StartAlgorithm()
+}
+
+// Close cierra los recursos de ambos lados del sub-líder.
+func (s *SubLeader) Close() {
+	s.group.Close()
+	s.follower.aAbstractNode.Close()
+}
+
+// HandleProcess implementa Handler: atiende GET_TIME, UPDATE_TIME y CLOSE del líder superior
+// coordinando al grupo, pasa al lado líder las confirmaciones de su grupo y delega el resto de
+// mensajes en el lado seguidor.
+func (s *SubLeader) HandleProcess(message string) (string, error) {
+	var data map[string]interface{}
+	if err := json.Unmarshal([]byte(message), &data); err != nil {
+		log.Printf("Error al deserializar el mensaje JSON: %v", err)
+		return `{"error":"Error al procesar el mensaje JSON"}`, nil
+	}
+	operation, _ := data["operation"].(string)
+
+	switch operation {
+	case "GET_TIME":
+		round, _ := data["round"].(float64)
+		return s.handleGetTime(messageVersion(data), int(round)), nil
+	case "UPDATE_TIME":
+		version := messageVersion(data)
+		delta, err := wireInt64(data, "delta")
+		if err != nil {
+			return `{"error":"Falta el campo delta"}`, nil
+		}
+		rate, _ := data["rate"].(float64)
+		id, _ := data["id"].(string)
+		reply, applied := s.follower.updates.apply(id, func() string {
+			return s.handleUpdateTime(fromWire(delta, version), rate, version)
+		})
+		if !applied {
+			log.Printf("🔁 Corrección %s repetida en el sub-líder %s: no se vuelve a aplicar", id, s.follower.aAbstractNode.Name)
+		}
+		return reply, nil
+	case "ACK_UPDATE":
+		return s.group.HandleProcess(message)
+	case "CLOSE":
+		s.closeGroup()
+		return s.follower.HandleProcess(message)
+	default:
+		return s.follower.HandleProcess(message)
+	}
+}
+
+// HandleAsync implementa AsyncHandler: un CLOSE asíncrono del líder superior cierra también el grupo,
+// las confirmaciones del grupo pasan al lado líder y el resto de notificaciones las atiende el lado
+// seguidor.
+func (s *SubLeader) HandleAsync(message string) {
+	var data map[string]interface{}
+	if err := json.Unmarshal([]byte(message), &data); err == nil {
+		switch data["operation"] {
+		case "CLOSE":
+			s.closeGroup()
+		case "ACK_UPDATE":
+			s.group.HandleAsync(message)
+			return
+		}
+	}
+	s.follower.HandleAsync(message)
+}
+
+// closeGroup envía el mensaje de cierre a todos los miembros del grupo.
+func (s *SubLeader) closeGroup() {
+	log.Printf("🔌 Operación CLOSE en el sub-líder %s: se cierra también su grupo", s.follower.aAbstractNode.Name)
+	s.group.mu.Lock()
+	members := make(map[string]*FollowerInfo, len(s.group.aAbstractNode.NodeAddresses))
+	for name, address := range s.group.aAbstractNode.NodeAddresses {
+		members[name] = &FollowerInfo{Name: name, Address: address}
+	}
+	s.group.sendCloseMessagesToFollowers(members)
+	s.group.mu.Unlock()
+}
+
+// handleGetTime responde al líder superior con el tiempo acordado por el grupo: las marcas de
+// recepción (T2) y envío (T3) se desplazan con la corrección que el grupo calculó para el sub-líder. El
+// primer GET_TIME de cada ronda (round) del líder superior sincroniza al grupo (peticiones de tiempo y
+// cálculo de correcciones, sin aplicarlas); los siguientes de la misma ronda reutilizan ese resultado
+// hasta que llega la corrección. La respuesta va en la versión del protocolo del líder superior.
+func (s *SubLeader) handleGetTime(version, round int) string {
+	T2 := s.follower.getCurrentTime()
+	received := s.follower.clock.Monotonic()
+	s.follower.markLeaderContact()
+
+	s.mu.Lock()
+	if !s.synced || s.round != round {
+		s.group.mu.Lock()
+		s.group.resetStructs()
+		s.group.processFollowers(context.Background())
+		s.pending, s.pendingSelf = s.group.calculateDeltaTimeDifference()
+		s.group.recordDriftSamples()
+		s.group.mu.Unlock()
+		s.synced, s.round = true, round
+	} else {
+		log.Printf("Sub-líder %s: el grupo ya se sincronizó en la ronda %d del líder superior", s.follower.aAbstractNode.Name, round)
+	}
+	selfDelta := s.pendingSelf
+	s.mu.Unlock()
+
+	T3 := T2 + int64(s.follower.clock.Monotonic()-received)
+	log.Printf("⏰ Sub-líder %s: tiempo acordado por el grupo desplazado %v respecto al suyo", s.follower.aAbstractNode.Name, time.Duration(selfDelta))
+	return s.follower.timeReply(T2+selfDelta, T3+selfDelta, version)
+}
+
+// handleUpdateTime suma la corrección del líder superior (delta, en nanosegundos) a las calculadas por
+// el grupo y las aplica: cada miembro recibe la suya y el sub-líder corrige su propio reloj. La
+// corrección de frecuencia del líder superior se aplica al sub-líder y se propaga a los miembros.
+func (s *SubLeader) handleUpdateTime(delta int64, rate float64, version int) string {
+	s.follower.markLeaderContact()
+
+	s.mu.Lock()
+	pending, pendingSelf := s.pending, s.pendingSelf
+	s.pending, s.pendingSelf, s.synced = nil, 0, false
+	s.mu.Unlock()
+
+	log.Printf("🔄 Sub-líder %s: corrección del líder superior %v sobre las del grupo %v", s.follower.aAbstractNode.Name, time.Duration(delta), pending)
+
+	s.group.mu.Lock()
+	corrections := make(map[string]int64, len(pending))
+	for name, correction := range pending {
+		corrections[name] = correction + delta
+		if follower, ok := s.group.SuccessfulFollowers[name]; ok {
+			follower.RateCorrection += rate
+		}
+	}
+	if len(corrections) > 0 {
+		s.group.callFollowersWithUpdatedTime(context.Background(), corrections)
+	}
+	s.group.mu.Unlock()
+
+	if rate != 0 {
+		s.follower.adjustRate(rate)
+	}
+	reply := s.follower.modSystemTime(pendingSelf+delta, version)
+
+	// Llevar la cuenta de las correcciones propias para la estimación de la deriva del grupo
+	s.group.mu.Lock()
+	s.group.ClockOffset += time.Duration(pendingSelf + delta)
+	s.group.LeaderDelta = time.Duration(pendingSelf + delta)
+	s.group.printResults()
+	s.group.mu.Unlock()
+
+	return reply
+}
+
+// String devuelve una descripción breve del sub-líder.
+func (s *SubLeader) String() string {
+	return fmt.Sprintf("SubLeader(%s, %s)", s.follower.aAbstractNode.Name, s.follower.aAbstractNode.Address)
+}
@@ -0,0 +1,144 @@
+package berkeley
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+)
+
+// Transport es el medio por el que un nodo intercambia mensajes con los demás. AbstractNode sólo
+// depende de esta interfaz, de modo que los nodos pueden hablar por ZeroMQ, por TCP sin bibliotecas
+// externas o, dentro de un mismo proceso, por memoria.
+type Transport interface {
+	// Request envía un mensaje a la dirección y espera su respuesta hasta el timeout del transporte.
+	// Si el intercambio falla, la conexión con esa dirección se descarta y el siguiente envío abre otra.
+	Request(address, message string) (string, error)
+	// Send envía un mensaje a la dirección sin esperar respuesta. Lo recibe el HandleAsync del handler
+	// que escucha en ella.
+	Send(address, message string) error
+	// Listen atiende en la dirección los mensajes entrantes con el handler, con hasta workers mensajes
+	// a la vez. Si el handler es un AsyncHandler recibe también los mensajes enviados con Send.
+	// Devuelve en cuanto el transporte está escuchando.
+	Listen(address string, handler Handler, workers int) error
+	// Drop cierra la conexión abierta hacia una dirección, si la había.
+	Drop(address string)
+	// Close deja de escuchar y cierra todas las conexiones; los envíos posteriores fallan con
+	// ErrTransportClosed.
+	Close() error
+}
+
+// asyncRequester lo implementan los transportes con un mecanismo propio para lanzar peticiones sin
+// esperar la respuesta. Con los demás, SendRequestAsync usa Request desde una goroutine.
+type asyncRequester interface {
+	RequestAsync(address, message string) <-chan AsyncReply
+}
+
+// Broadcaster lo implementan los transportes con un canal de difusión, por el que un nodo envía un
+// mismo mensaje a todos los que lo escuchan. La entrega no está garantizada: un suscriptor que se
+// conecta tarde o va retrasado pierde mensajes.
+type Broadcaster interface {
+	// Bind enlaza la dirección de difusión sin enviar nada, para que los suscriptores puedan conectarse
+	// antes del primer mensaje. Enlazar una dirección ya enlazada no hace nada.
+	Bind(address string) error
+	// Publish difunde el mensaje en la dirección, que el transporte enlaza si aún no lo estaba.
+	Publish(address, message string) error
+	// Subscribe entrega a handler, uno a uno, los mensajes difundidos en la dirección.
+	Subscribe(address string, handler func(message string)) error
+}
+
+// DefaultAsyncPortOffset es la distancia por defecto entre el puerto de un nodo y el de su socket PULL
+// en el transporte ZeroMQ.
+const DefaultAsyncPortOffset = 1000
+
+// AsyncPortOffset es la distancia entre el puerto de un nodo y el de su socket PULL en el transporte
+// ZeroMQ, en el que recibe los mensajes sin respuesta: con 1000, un nodo en 127.0.0.1:8081 los recibe en
+// 127.0.0.1:9081. Todos los nodos deben usar la misma, y ningún puerto desplazado debe coincidir con el
+// de otro nodo o canal de difusión.
+var AsyncPortOffset = DefaultAsyncPortOffset
+
+// asyncAddress devuelve la dirección del socket PULL de un nodo.
+func asyncAddress(address string) (string, error) {
+	return shiftPort(address, AsyncPortOffset)
+}
+
+// shiftPort devuelve la dirección con el puerto desplazado offset posiciones.
+func shiftPort(address string, offset int) (string, error) {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return "", fmt.Errorf("dirección no válida %s: %w", address, err)
+	}
+	number, err := strconv.Atoi(port)
+	if err != nil {
+		return "", fmt.Errorf("puerto no válido en %s: %w", address, err)
+	}
+	return net.JoinHostPort(host, strconv.Itoa(number+offset)), nil
+}
+
+// ErrTransportClosed se devuelve al enviar por un transporte que ya se cerró.
+var ErrTransportClosed = errors.New("el transporte está cerrado")
+
+// Nombres de los transportes disponibles.
+const (
+	ZMQTransportName    = "zmq"
+	TCPTransportName    = "tcp"
+	MemoryTransportName = "memory"
+)
+
+// TransportFactory crea el transporte de un nodo nuevo con el timeout de respuesta indicado.
+type TransportFactory func(timeout time.Duration) (Transport, error)
+
+// DefaultTransport es la fábrica con la que NewAbstractNode crea el transporte de cada nodo: ZeroMQ,
+// o TCP si el paquete se compila con la etiqueta nozmq.
+var DefaultTransport TransportFactory = defaultTransportFactory
+
+// NewTransportFactory devuelve la fábrica del transporte con el nombre indicado: zmq, tcp o memory. Con
+// memory todos los nodos creados con la fábrica comparten una misma red en memoria. Un nombre vacío
+// devuelve DefaultTransport.
+func NewTransportFactory(name string) (TransportFactory, error) {
+	switch name {
+	case "":
+		return DefaultTransport, nil
+	case ZMQTransportName:
+		return func(timeout time.Duration) (Transport, error) {
+			transport, err := NewZMQTransport(timeout)
+			if err != nil {
+				return nil, err
+			}
+			return transport, nil
+		}, nil
+	case TCPTransportName:
+		return func(timeout time.Duration) (Transport, error) {
+			return NewTCPTransport(timeout), nil
+		}, nil
+	case MemoryTransportName:
+		network := NewMemoryNetwork()
+		return func(timeout time.Duration) (Transport, error) {
+			return network.Transport(timeout), nil
+		}, nil
+	default:
+		return nil, fmt.Errorf("transporte desconocido: %s", name)
+	}
+}
+
+// handleMessage pasa un mensaje recibido al handler y devuelve la respuesta. El remitente espera una
+// respuesta: si el handler falla se le devuelve el error en lugar de dejarlo bloqueado.
+func handleMessage(handler Handler, message string) string {
+	response, err := handler.HandleProcess(message)
+	if err != nil {
+		return fmt.Sprintf(`{"error":%q}`, err.Error())
+	}
+	return response
+}
+
+// handleAsyncMessage pasa un mensaje sin respuesta al handler si es un AsyncHandler. Devuelve false si
+// el handler no admite mensajes asíncronos y el mensaje se descarta.
+func handleAsyncMessage(handler Handler, message string) bool {
+	async, ok := handler.(AsyncHandler)
+	if !ok {
+		return false
+	}
+	async.HandleAsync(message)
+	return true
+}
@@ -0,0 +1,241 @@
+package berkeley
+
+import (
+	"errors"
+	"log"
+	"sync"
+	"time"
+)
+
+// MemoryNetwork es una red en memoria que comunica nodos de un mismo proceso sin abrir sockets. Cada
+// nodo obtiene su transporte con Transport; los mensajes se entregan directamente al handler que
+// escucha en la dirección de destino.
+type MemoryNetwork struct {
+	mu          sync.Mutex
+	listeners   map[string]*memoryListener
+	subscribers map[string][]*memorySubscriber // Suscriptores por dirección de difusión
+}
+
+// memorySubscriber es una suscripción a una dirección de difusión de la red. Sus mensajes se entregan
+// en orden desde una única goroutine.
+type memorySubscriber struct {
+	messages chan string
+	owner    *MemoryTransport
+}
+
+// memoryListener es el handler que escucha en una dirección de la red, con sus plazas de trabajo.
+type memoryListener struct {
+	handler Handler
+	slots   chan struct{} // Limita los mensajes atendidos a la vez
+	owner   *MemoryTransport
+}
+
+// NewMemoryNetwork crea una red en memoria vacía.
+func NewMemoryNetwork() *MemoryNetwork {
+	return &MemoryNetwork{listeners: make(map[string]*memoryListener), subscribers: make(map[string][]*memorySubscriber)}
+}
+
+// Transport crea un transporte conectado a la red con el timeout de respuesta indicado.
+func (m *MemoryNetwork) Transport(timeout time.Duration) *MemoryTransport {
+	return &MemoryTransport{Logger: log.Default(), network: m, timeout: timeout}
+}
+
+// lookup devuelve el handler que escucha en la dirección.
+func (m *MemoryNetwork) lookup(address string) (*memoryListener, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	listener, ok := m.listeners[address]
+	if !ok {
+		return nil, errors.New("error al conectar con " + address)
+	}
+	return listener, nil
+}
+
+// MemoryTransport es el transporte de un nodo sobre una MemoryNetwork. Permite ejecutar y probar
+// varios nodos en un mismo proceso sin red; un destino que no escucha o no responde a tiempo produce
+// los mismos errores que con los transportes de red.
+type MemoryTransport struct {
+	Logger *log.Logger
+
+	network *MemoryNetwork
+	timeout time.Duration // Tiempo máximo de espera de cada respuesta (sin límite si es 0)
+
+	mu        sync.Mutex
+	addresses []string // Direcciones en las que escucha este transporte
+	closed    bool
+}
+
+// isClosed indica si el transporte ya se cerró.
+func (t *MemoryTransport) isClosed() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.closed
+}
+
+// deliver entrega el mensaje al handler de la dirección y devuelve el canal por el que llegará su respuesta.
+func (t *MemoryTransport) deliver(address, message string) (<-chan string, error) {
+	if t.isClosed() {
+		return nil, ErrTransportClosed
+	}
+	listener, err := t.network.lookup(address)
+	if err != nil {
+		return nil, err
+	}
+
+	replies := make(chan string, 1)
+	go func() {
+		listener.slots <- struct{}{}
+		defer func() { <-listener.slots }()
+		replies <- handleMessage(listener.handler, message)
+	}()
+	return replies, nil
+}
+
+// Request entrega el mensaje al handler de la dirección y espera su respuesta hasta el timeout.
+func (t *MemoryTransport) Request(address, message string) (string, error) {
+	replies, err := t.deliver(address, message)
+	if err != nil {
+		return "", err
+	}
+	if t.timeout <= 0 {
+		return <-replies, nil
+	}
+
+	timer := time.NewTimer(t.timeout)
+	defer timer.Stop()
+	select {
+	case reply := <-replies:
+		return reply, nil
+	case <-timer.C:
+		return "", errors.New("no se recibió respuesta del socket")
+	}
+}
+
+// Send entrega el mensaje al HandleAsync del handler de la dirección sin esperar a que lo procese.
+func (t *MemoryTransport) Send(address, message string) error {
+	if t.isClosed() {
+		return ErrTransportClosed
+	}
+	listener, err := t.network.lookup(address)
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		listener.slots <- struct{}{}
+		defer func() { <-listener.slots }()
+		if !handleAsyncMessage(listener.handler, message) {
+			t.Logger.Printf("Mensaje asíncrono descartado en %s: el nodo no los admite", address)
+		}
+	}()
+	return nil
+}
+
+// Listen registra el handler en la dirección de la red. Con workers menor que 1 se atiende un mensaje
+// a la vez.
+func (t *MemoryTransport) Listen(address string, handler Handler, workers int) error {
+	if workers < 1 {
+		workers = 1
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closed {
+		return ErrTransportClosed
+	}
+
+	t.network.mu.Lock()
+	defer t.network.mu.Unlock()
+	if _, ok := t.network.listeners[address]; ok {
+		return errors.New("error al enlazar el socket en " + address)
+	}
+	t.network.listeners[address] = &memoryListener{handler: handler, slots: make(chan struct{}, workers), owner: t}
+	t.addresses = append(t.addresses, address)
+	t.Logger.Printf("Escuchando en memoria en %s", address)
+	return nil
+}
+
+// memorySubscriberBuffer es el número de mensajes difundidos que un suscriptor puede tener pendientes;
+// si va más retrasado pierde los siguientes, como con un socket SUB.
+const memorySubscriberBuffer = 64
+
+// Bind no hace nada: en memoria los suscriptores reciben los mensajes desde que se suscriben.
+func (t *MemoryTransport) Bind(address string) error {
+	if t.isClosed() {
+		return ErrTransportClosed
+	}
+	return nil
+}
+
+// Publish entrega el mensaje a los suscriptores de la dirección.
+func (t *MemoryTransport) Publish(address, message string) error {
+	if t.isClosed() {
+		return ErrTransportClosed
+	}
+	t.network.mu.Lock()
+	defer t.network.mu.Unlock()
+	for _, subscriber := range t.network.subscribers[address] {
+		select {
+		case subscriber.messages <- message:
+		default:
+			t.Logger.Printf("Suscriptor de %s retrasado: se descarta un mensaje difundido", address)
+		}
+	}
+	return nil
+}
+
+// Subscribe registra el handler como suscriptor de la dirección.
+func (t *MemoryTransport) Subscribe(address string, handler func(message string)) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closed {
+		return ErrTransportClosed
+	}
+
+	subscriber := &memorySubscriber{messages: make(chan string, memorySubscriberBuffer), owner: t}
+	t.network.mu.Lock()
+	t.network.subscribers[address] = append(t.network.subscribers[address], subscriber)
+	t.network.mu.Unlock()
+
+	go func() {
+		for message := range subscriber.messages {
+			handler(message)
+		}
+	}()
+	return nil
+}
+
+// Drop no hace nada: el transporte en memoria no mantiene conexiones.
+func (t *MemoryTransport) Drop(address string) {}
+
+// Close retira de la red los handlers y las suscripciones de este transporte.
+func (t *MemoryTransport) Close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closed {
+		return nil
+	}
+	t.closed = true
+
+	t.network.mu.Lock()
+	defer t.network.mu.Unlock()
+	for _, address := range t.addresses {
+		if listener, ok := t.network.listeners[address]; ok && listener.owner == t {
+			delete(t.network.listeners, address)
+		}
+	}
+	t.addresses = nil
+
+	// Las suscripciones de este transporte se retiran y terminan
+	for address, subscribers := range t.network.subscribers {
+		kept := subscribers[:0]
+		for _, subscriber := range subscribers {
+			if subscriber.owner == t {
+				close(subscriber.messages)
+			} else {
+				kept = append(kept, subscriber)
+			}
+		}
+		t.network.subscribers[address] = kept
+	}
+	return nil
+}
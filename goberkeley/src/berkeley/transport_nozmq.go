@@ -0,0 +1,18 @@
+//go:build nozmq
+
+package berkeley
+
+import (
+	"errors"
+	"time"
+)
+
+// defaultTransportFactory crea un transporte TCP: el paquete se ha compilado sin ZeroMQ.
+func defaultTransportFactory(timeout time.Duration) (Transport, error) {
+	return NewTCPTransport(timeout), nil
+}
+
+// NewZMQTransport no está disponible al compilar con la etiqueta nozmq.
+func NewZMQTransport(timeout time.Duration) (Transport, error) {
+	return nil, errors.New("transporte ZeroMQ no disponible: compilado con la etiqueta nozmq")
+}
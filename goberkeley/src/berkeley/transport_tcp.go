@@ -0,0 +1,315 @@
+package berkeley
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"sync"
+	"time"
+)
+
+// Tipos de trama del transporte TCP.
+const (
+	tcpFrameRequest byte = 'Q' // Petición que espera respuesta
+	tcpFrameReply   byte = 'R' // Respuesta a una petición
+	tcpFrameOneWay  byte = 'M' // Mensaje sin respuesta
+)
+
+// maxTCPFrameSize es el mayor cuerpo de trama que se acepta; protege de longitudes corruptas.
+const maxTCPFrameSize = 16 << 20
+
+// TCPTransport es un transporte sobre TCP que sólo usa la biblioteca estándar. Cada mensaje viaja en
+// una trama con un byte de tipo, la longitud del cuerpo en 4 bytes big-endian y el cuerpo. Como el
+// transporte ZeroMQ, mantiene una conexión abierta por destino y la descarta si un intercambio falla.
+// Las direcciones son las mismas (host:puerto), pero los dos transportes no se entienden entre sí.
+type TCPTransport struct {
+	Logger *log.Logger
+
+	timeout time.Duration // Tiempo máximo de espera de cada respuesta (sin límite si es 0)
+
+	mu          sync.Mutex
+	connections map[string]*tcpConnection // Conexiones salientes por dirección
+	listeners   []net.Listener
+	accepted    map[net.Conn]struct{} // Conexiones entrantes abiertas
+	closed      bool
+}
+
+// tcpConnection es la conexión saliente hacia una dirección. Cada intercambio la usa con el mutex
+// bloqueado, porque las respuestas llegan en el orden de las peticiones.
+type tcpConnection struct {
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+// NewTCPTransport crea un transporte TCP con el timeout de respuesta indicado.
+func NewTCPTransport(timeout time.Duration) *TCPTransport {
+	return &TCPTransport{
+		Logger:      log.Default(),
+		timeout:     timeout,
+		connections: make(map[string]*tcpConnection),
+		accepted:    make(map[net.Conn]struct{}),
+	}
+}
+
+// connection devuelve la conexión de una dirección, creándola vacía si no existía.
+func (t *TCPTransport) connection(address string) (*tcpConnection, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closed {
+		return nil, ErrTransportClosed
+	}
+	conn, ok := t.connections[address]
+	if !ok {
+		conn = &tcpConnection{}
+		t.connections[address] = conn
+	}
+	return conn, nil
+}
+
+// deadline devuelve el instante límite de un intercambio que empieza ahora.
+func (t *TCPTransport) deadline() time.Time {
+	if t.timeout <= 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(t.timeout)
+}
+
+// dial abre una conexión con la dirección, esperando como mucho el timeout.
+func (t *TCPTransport) dial(address string) (net.Conn, error) {
+	conn, err := net.DialTimeout("tcp", address, t.timeout)
+	if err != nil {
+		return nil, errors.New("error al conectar con " + address)
+	}
+	return conn, nil
+}
+
+// Request envía el mensaje por la conexión abierta hacia la dirección, abriéndola si hace falta, y
+// espera la respuesta. Si el intercambio falla la conexión se descarta.
+func (t *TCPTransport) Request(address, message string) (string, error) {
+	c, err := t.connection(address)
+	if err != nil {
+		return "", err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.conn == nil {
+		conn, err := t.dial(address)
+		if err != nil {
+			return "", err
+		}
+		c.conn = conn
+	}
+
+	c.conn.SetDeadline(t.deadline())
+	if err := writeTCPFrame(c.conn, tcpFrameRequest, message); err != nil {
+		c.reset()
+		return "", errors.New("error al enviar el mensaje")
+	}
+	kind, reply, err := readTCPFrame(c.conn)
+	if err != nil || kind != tcpFrameReply {
+		// Una respuesta tardía llegaría después por esta conexión y se tomaría por la de la siguiente petición
+		c.reset()
+		return "", errors.New("no se recibió respuesta del socket")
+	}
+	return reply, nil
+}
+
+// reset cierra y descarta la conexión. Requiere el mutex de la conexión bloqueado.
+func (c *tcpConnection) reset() {
+	if c.conn != nil {
+		c.conn.Close()
+		c.conn = nil
+	}
+}
+
+// Send abre una conexión, envía el mensaje sin esperar respuesta y la cierra.
+func (t *TCPTransport) Send(address, message string) error {
+	t.mu.Lock()
+	closed := t.closed
+	t.mu.Unlock()
+	if closed {
+		return ErrTransportClosed
+	}
+
+	conn, err := t.dial(address)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	conn.SetDeadline(t.deadline())
+	if err := writeTCPFrame(conn, tcpFrameOneWay, message); err != nil {
+		return errors.New("error al enviar el mensaje")
+	}
+	return nil
+}
+
+// Listen acepta conexiones en la dirección y atiende sus mensajes con el handler. Cada conexión se lee
+// en su propia goroutine y sus peticiones se responden en orden; entre todas las conexiones se atienden
+// como mucho workers mensajes a la vez, y al menos uno.
+func (t *TCPTransport) Listen(address string, handler Handler, workers int) error {
+	if workers < 1 {
+		workers = 1
+	}
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		t.Logger.Printf("Error al escuchar en %s: %v", address, err)
+		return errors.New("error al enlazar el socket en " + address)
+	}
+
+	t.mu.Lock()
+	if t.closed {
+		t.mu.Unlock()
+		listener.Close()
+		return ErrTransportClosed
+	}
+	t.listeners = append(t.listeners, listener)
+	t.mu.Unlock()
+
+	slots := make(chan struct{}, workers)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				// Al cerrar el transporte se cierra el listener y la aceptación falla
+				t.Logger.Printf("Fin de la escucha en %s: %v", address, err)
+				return
+			}
+			if !t.track(conn) {
+				conn.Close()
+				return
+			}
+			go t.serveConnection(address, conn, handler, slots)
+		}
+	}()
+	return nil
+}
+
+// track registra una conexión entrante para cerrarla con el transporte. Devuelve false si el
+// transporte ya está cerrado.
+func (t *TCPTransport) track(conn net.Conn) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closed {
+		return false
+	}
+	t.accepted[conn] = struct{}{}
+	return true
+}
+
+// serveConnection lee las tramas de una conexión entrante hasta que se cierra.
+func (t *TCPTransport) serveConnection(address string, conn net.Conn, handler Handler, slots chan struct{}) {
+	defer func() {
+		t.mu.Lock()
+		delete(t.accepted, conn)
+		t.mu.Unlock()
+		conn.Close()
+	}()
+
+	reader := bufio.NewReader(conn)
+	for {
+		kind, message, err := readTCPFrame(reader)
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				t.Logger.Printf("Conexión con %s cerrada en %s: %v", conn.RemoteAddr(), address, err)
+			}
+			return
+		}
+		t.Logger.Printf("Mensaje recibido en %s: %v", address, message)
+
+		switch kind {
+		case tcpFrameRequest:
+			slots <- struct{}{}
+			response := handleMessage(handler, message)
+			<-slots
+			t.Logger.Printf("Enviando respuesta en %s: %v", address, response)
+			if err := writeTCPFrame(conn, tcpFrameReply, response); err != nil {
+				t.Logger.Printf("Error al enviar respuesta en %s: %v", address, err)
+				return
+			}
+		case tcpFrameOneWay:
+			slots <- struct{}{}
+			go func() {
+				defer func() { <-slots }()
+				if !handleAsyncMessage(handler, message) {
+					t.Logger.Printf("Mensaje asíncrono descartado en %s: el nodo no los admite", address)
+				}
+			}()
+		default:
+			t.Logger.Printf("Trama desconocida %q recibida en %s", kind, address)
+			return
+		}
+	}
+}
+
+// Drop cierra la conexión abierta hacia una dirección, si la había.
+func (t *TCPTransport) Drop(address string) {
+	t.mu.Lock()
+	c, ok := t.connections[address]
+	delete(t.connections, address)
+	t.mu.Unlock()
+
+	if ok {
+		c.mu.Lock()
+		c.reset()
+		c.mu.Unlock()
+	}
+}
+
+// Close deja de escuchar y cierra las conexiones entrantes y salientes.
+func (t *TCPTransport) Close() error {
+	t.mu.Lock()
+	if t.closed {
+		t.mu.Unlock()
+		return nil
+	}
+	t.closed = true
+	listeners, connections, accepted := t.listeners, t.connections, t.accepted
+	t.listeners, t.connections, t.accepted = nil, make(map[string]*tcpConnection), make(map[net.Conn]struct{})
+	t.mu.Unlock()
+
+	for _, listener := range listeners {
+		listener.Close()
+	}
+	for conn := range accepted {
+		conn.Close()
+	}
+	for _, c := range connections {
+		c.mu.Lock()
+		c.reset()
+		c.mu.Unlock()
+	}
+	return nil
+}
+
+// writeTCPFrame escribe una trama con el tipo y el cuerpo indicados.
+func writeTCPFrame(w io.Writer, kind byte, body string) error {
+	frame := make([]byte, 5+len(body))
+	frame[0] = kind
+	binary.BigEndian.PutUint32(frame[1:5], uint32(len(body)))
+	copy(frame[5:], body)
+	_, err := w.Write(frame)
+	return err
+}
+
+// readTCPFrame lee una trama y devuelve su tipo y su cuerpo.
+func readTCPFrame(r io.Reader) (byte, string, error) {
+	var header [5]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return 0, "", err
+	}
+	size := binary.BigEndian.Uint32(header[1:5])
+	if size > maxTCPFrameSize {
+		return 0, "", fmt.Errorf("trama de %d bytes demasiado grande", size)
+	}
+	body := make([]byte, size)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return 0, "", err
+	}
+	return header[0], string(body), nil
+}
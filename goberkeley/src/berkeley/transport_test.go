@@ -0,0 +1,81 @@
+package berkeley
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+// echoHandler responde a cada mensaje con el mismo mensaje precedido de "eco".
+type echoHandler struct{}
+
+func (echoHandler) HandleProcess(message string) (string, error) {
+	return "eco " + message, nil
+}
+
+// freeTCPAddress devuelve una dirección local con un puerto libre.
+func freeTCPAddress(t *testing.T) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	return listener.Addr().String()
+}
+
+func TestTransportRequestReply(t *testing.T) {
+	tests := []struct {
+		name    string
+		address func(t *testing.T) string
+		new     func() (server, client Transport)
+	}{
+		{
+			name:    "memoria",
+			address: func(*testing.T) string { return "memory:eco" },
+			new: func() (Transport, Transport) {
+				network := NewMemoryNetwork()
+				return network.Transport(time.Second), network.Transport(time.Second)
+			},
+		},
+		{
+			name:    "tcp",
+			address: freeTCPAddress,
+			new: func() (Transport, Transport) {
+				return NewTCPTransport(time.Second), NewTCPTransport(time.Second)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, client := tt.new()
+			defer server.Close()
+			address := tt.address(t)
+
+			// Sin trabajadores indicados se atiende igualmente un mensaje a la vez
+			if err := server.Listen(address, echoHandler{}, 0); err != nil {
+				t.Fatal(err)
+			}
+			if err := server.Listen(address, echoHandler{}, 1); err == nil {
+				t.Error("se esperaba un error al escuchar dos veces en la misma dirección")
+			}
+			for _, message := range []string{"uno", "dos"} {
+				reply, err := client.Request(address, message)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if want := "eco " + message; reply != want {
+					t.Errorf("respuesta %q, se esperaba %q", reply, want)
+				}
+			}
+
+			if err := client.Close(); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := client.Request(address, "tres"); !errors.Is(err, ErrTransportClosed) {
+				t.Errorf("petición tras cerrar: %v, se esperaba ErrTransportClosed", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,402 @@
+//go:build !nozmq
+
+package berkeley
+
+import (
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	zmq "github.com/pebbe/zmq4" // Librería para trabajar con ZeroMQ
+)
+
+// defaultTransportFactory crea un transporte ZeroMQ.
+func defaultTransportFactory(timeout time.Duration) (Transport, error) {
+	transport, err := NewZMQTransport(timeout)
+	if err != nil {
+		return nil, err
+	}
+	return transport, nil
+}
+
+// ZMQTransport es el transporte sobre ZeroMQ. Las peticiones síncronas viajan por un socket REQ por
+// destino que se reutiliza entre intercambios, las asíncronas por un socket DEALER propio y los envíos
+// sin respuesta por un socket PUSH hacia el socket PULL del destino, en su puerto más AsyncPortOffset.
+// La escucha usa un socket ROUTER, junto al PULL, y el canal de difusión, sockets PUB y SUB.
+type ZMQTransport struct {
+	Context *zmq.Context
+	Logger  *log.Logger
+
+	timeout     time.Duration   // Tiempo máximo de espera de cada respuesta
+	connections *connectionPool // Sockets REQ abiertos hacia los demás nodos
+
+	mu         sync.Mutex
+	publishers map[string]*zmq.Socket // Sockets PUB enlazados por dirección de difusión
+	closed     bool
+}
+
+// NewZMQTransport crea un transporte ZeroMQ con su propio contexto.
+func NewZMQTransport(timeout time.Duration) (*ZMQTransport, error) {
+	context, err := zmq.NewContext()
+	if err != nil {
+		return nil, errors.New("error al crear el contexto ZeroMQ")
+	}
+	return &ZMQTransport{
+		Context:     context,
+		Logger:      log.Default(),
+		timeout:     timeout,
+		connections: newConnectionPool(context, timeout),
+		publishers:  make(map[string]*zmq.Socket),
+	}, nil
+}
+
+// Request envía el mensaje por el socket REQ que el transporte mantiene abierto hacia esa dirección, de
+// modo que los intercambios sucesivos con el mismo nodo no pagan una nueva conexión.
+func (t *ZMQTransport) Request(address, message string) (string, error) {
+	reply, err := t.connections.request(address, message)
+	if errors.Is(err, errPoolClosed) {
+		return "", ErrTransportClosed
+	}
+	return reply, err
+}
+
+// Send envía un mensaje por un socket PUSH al socket PULL del destino sin esperar respuesta. El
+// mensaje pendiente de entrega se conserva como mucho el timeout tras cerrar el socket.
+func (t *ZMQTransport) Send(address, message string) error {
+	pullAddress, err := asyncAddress(address)
+	if err != nil {
+		return err
+	}
+	socket, err := t.Context.NewSocket(zmq.PUSH)
+	if err != nil {
+		return errors.New("error al crear el socket PUSH")
+	}
+	defer socket.Close()
+	socket.SetLinger(t.timeout)
+
+	err = socket.Connect("tcp://" + pullAddress)
+	if err != nil {
+		return errors.New("error al conectar con " + pullAddress)
+	}
+
+	_, err = socket.Send(message, 0)
+	if err != nil {
+		return errors.New("error al enviar el mensaje")
+	}
+	return nil
+}
+
+// RequestAsync envía una petición por un socket DEALER sin esperar la respuesta, que se entrega más
+// tarde en el canal devuelto. El mensaje lleva el marco vacío que espera un socket REP o ROUTER, y cada
+// petición usa su propio socket, por lo que la respuesta recibida corresponde siempre a esa petición.
+func (t *ZMQTransport) RequestAsync(address, message string) <-chan AsyncReply {
+	replies := make(chan AsyncReply, 1)
+	go func() {
+		reply, err := t.dealerRequest(address, message)
+		replies <- AsyncReply{Address: address, Reply: reply, Err: err}
+	}()
+	return replies
+}
+
+// dealerRequest realiza un intercambio por un socket DEALER propio y espera la respuesta hasta el timeout.
+func (t *ZMQTransport) dealerRequest(address, message string) (string, error) {
+	socket, err := t.Context.NewSocket(zmq.DEALER)
+	if err != nil {
+		return "", errors.New("error al crear el socket DEALER")
+	}
+	defer socket.Close()
+	socket.SetLinger(0)
+	socket.SetRcvtimeo(t.timeout)
+
+	if err := socket.Connect("tcp://" + address); err != nil {
+		return "", errors.New("error al conectar con " + address)
+	}
+	if _, err := socket.SendMessage("", message); err != nil {
+		return "", errors.New("error al enviar el mensaje")
+	}
+
+	parts, err := socket.RecvMessage(0)
+	if err != nil {
+		return "", errors.New("no se recibió respuesta del socket")
+	}
+	_, reply := splitEnvelope(parts)
+	return reply, nil
+}
+
+// Listen enlaza un socket ROUTER en la dirección y reparte los mensajes entre workers goroutines a
+// través de un socket DEALER interno, de modo que una petición lenta no retrasa a las demás. Cada
+// trabajador conserva el sobre de ZeroMQ del mensaje (la identidad del remitente) y lo antepone a la
+// respuesta, con lo que el ROUTER la devuelve a quien hizo la petición. Los clientes REQ y DEALER
+// pueden hablar con el nodo sin cambios. Siempre hay al menos un trabajador. Si algún socket no se
+// puede crear o enlazar se cierran los ya creados y no queda nada en marcha.
+func (t *ZMQTransport) Listen(address string, handler Handler, workers int) error {
+	if workers < 1 {
+		workers = 1
+	}
+	var sockets []*zmq.Socket
+	closeAll := func() {
+		for _, socket := range sockets {
+			socket.Close()
+		}
+	}
+
+	// Junto al ROUTER, un socket PULL recibe los mensajes sin respuesta si el handler los admite
+	var pull *zmq.Socket
+	var pullAddress string
+	if _, ok := handler.(AsyncHandler); ok {
+		var err error
+		pull, pullAddress, err = t.bindPull(address)
+		if err != nil {
+			return err
+		}
+		sockets = append(sockets, pull)
+	}
+
+	// Log para indicar que estamos intentando crear un socket ROUTER.
+	t.Logger.Printf("Intentando crear un socket ROUTER en la dirección %s", address)
+	frontend, err := t.Context.NewSocket(zmq.ROUTER)
+	if err != nil {
+		// Si ocurre un error al crear el socket, loguea el error y retorna un mensaje de error.
+		t.Logger.Printf("Error al crear el socket ROUTER: %v", err)
+		closeAll()
+		return errors.New("error al crear el socket ROUTER")
+	}
+	sockets = append(sockets, frontend)
+
+	// Enlaza el socket a la dirección TCP proporcionada para esperar conexiones.
+	t.Logger.Printf("Enlazando el socket ROUTER en la dirección tcp://%s", address)
+	if err := frontend.Bind("tcp://" + address); err != nil {
+		// Si ocurre un error al enlazar el socket, loguea el error y retorna un mensaje de error.
+		t.Logger.Printf("Error al enlazar el socket en %s: %v", address, err)
+		closeAll()
+		return errors.New("error al enlazar el socket en " + address)
+	}
+	t.Logger.Printf("Socket enlazado exitosamente en %s", address)
+
+	// Socket interno por el que el ROUTER reparte los mensajes entre los trabajadores
+	backendAddress := "inproc://workers-" + address
+	backend, err := t.Context.NewSocket(zmq.DEALER)
+	if err != nil {
+		closeAll()
+		return errors.New("error al crear el socket DEALER de los trabajadores")
+	}
+	sockets = append(sockets, backend)
+	if err := backend.Bind(backendAddress); err != nil {
+		closeAll()
+		return errors.New("error al enlazar el socket de los trabajadores en " + backendAddress)
+	}
+
+	workerSockets := make([]*zmq.Socket, 0, workers)
+	for i := 0; i < workers; i++ {
+		worker, err := t.Context.NewSocket(zmq.DEALER)
+		if err != nil {
+			closeAll()
+			return errors.New("error al crear el socket de un trabajador")
+		}
+		sockets = append(sockets, worker)
+		if err := worker.Connect(backendAddress); err != nil {
+			closeAll()
+			return errors.New("error al conectar un trabajador con " + backendAddress)
+		}
+		workerSockets = append(workerSockets, worker)
+	}
+
+	// Con todos los sockets preparados se ponen en marcha los trabajadores, el proxy y la escucha asíncrona
+	for i, worker := range workerSockets {
+		go t.serveWorker(address, i, worker, handler)
+	}
+
+	// El proxy reenvía las peticiones a los trabajadores y las respuestas al ROUTER hasta que se termina el
+	// contexto; después cierra sus sockets
+	go func() {
+		err := zmq.Proxy(frontend, backend, nil)
+		t.Logger.Printf("Fin de la escucha en %s: %v", address, err)
+		frontend.Close()
+		backend.Close()
+	}()
+
+	if pull != nil {
+		go t.serveAsync(address, pullAddress, pull, handler)
+	}
+	return nil
+}
+
+// bindPull enlaza el socket PULL del nodo en la dirección, en su puerto más AsyncPortOffset. Devuelve
+// el socket y la dirección en la que quedó enlazado.
+func (t *ZMQTransport) bindPull(address string) (*zmq.Socket, string, error) {
+	pullAddress, err := asyncAddress(address)
+	if err != nil {
+		return nil, "", err
+	}
+	pull, err := t.Context.NewSocket(zmq.PULL)
+	if err != nil {
+		return nil, "", errors.New("error al crear el socket PULL")
+	}
+	if err := pull.Bind("tcp://" + pullAddress); err != nil {
+		t.Logger.Printf("Error al enlazar el socket PULL en %s: %v", pullAddress, err)
+		pull.Close()
+		return nil, "", errors.New("error al enlazar el socket PULL en " + pullAddress)
+	}
+	t.Logger.Printf("Socket PULL enlazado en %s para los mensajes asíncronos de %s", pullAddress, address)
+	return pull, pullAddress, nil
+}
+
+// serveAsync entrega al handler, en orden, los mensajes que recibe el socket PULL hasta que se termina
+// el contexto.
+func (t *ZMQTransport) serveAsync(address, pullAddress string, pull *zmq.Socket, handler Handler) {
+	defer pull.Close()
+	for {
+		message, err := pull.Recv(0)
+		if err != nil {
+			// Al terminar el contexto la recepción falla y la escucha termina
+			t.Logger.Printf("Fin de la escucha asíncrona en %s: %v", pullAddress, err)
+			return
+		}
+		t.Logger.Printf("Mensaje asíncrono recibido en %s: %v", address, message)
+		handleAsyncMessage(handler, message)
+	}
+}
+
+// serveWorker atiende los mensajes que el proxy entrega al trabajador id. Cada mensaje llega con su
+// sobre: los marcos de identidad, un marco vacío y el cuerpo. La respuesta se envía con el mismo sobre.
+func (t *ZMQTransport) serveWorker(address string, id int, socket *zmq.Socket, handler Handler) {
+	defer socket.Close()
+	for {
+		parts, err := socket.RecvMessage(0)
+		if err != nil {
+			// Al terminar el contexto la recepción falla y el trabajador termina
+			t.Logger.Printf("Trabajador %d de %s terminado: %v", id, address, err)
+			return
+		}
+		envelope, message := splitEnvelope(parts)
+		t.Logger.Printf("Mensaje recibido en %s (trabajador %d): %v", address, id, message)
+
+		response := handleMessage(handler, message)
+
+		// Envía la respuesta de vuelta al cliente con el mismo sobre con el que llegó la petición.
+		t.Logger.Printf("Enviando respuesta en %s (trabajador %d): %v", address, id, response)
+		if _, err := socket.SendMessage(envelope, response); err != nil {
+			t.Logger.Printf("Error al enviar respuesta en %s: %v", address, err)
+		}
+	}
+}
+
+// splitEnvelope separa un mensaje multiparte de ZeroMQ en su sobre, hasta el marco vacío incluido, y el
+// cuerpo, que es el último marco.
+func splitEnvelope(parts []string) ([]string, string) {
+	if len(parts) == 0 {
+		return nil, ""
+	}
+	for i, part := range parts {
+		if part == "" && i+1 < len(parts) {
+			return parts[:i+1], parts[len(parts)-1]
+		}
+	}
+	return parts[:len(parts)-1], parts[len(parts)-1]
+}
+
+// Bind enlaza el socket PUB de la dirección. Los suscriptores conectados antes del primer Publish
+// reciben todos los mensajes difundidos.
+func (t *ZMQTransport) Bind(address string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closed {
+		return ErrTransportClosed
+	}
+	_, err := t.publisher(address)
+	return err
+}
+
+// Publish difunde el mensaje por el socket PUB enlazado en la dirección, creándolo si aún no existe.
+// Los suscriptores que se conectan después del envío no lo reciben.
+func (t *ZMQTransport) Publish(address, message string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closed {
+		return ErrTransportClosed
+	}
+
+	publisher, err := t.publisher(address)
+	if err != nil {
+		return err
+	}
+	if _, err := publisher.Send(message, 0); err != nil {
+		return errors.New("error al difundir el mensaje")
+	}
+	return nil
+}
+
+// publisher devuelve el socket PUB enlazado en la dirección, creándolo la primera vez. Quien la llama
+// debe tener bloqueado el mutex del transporte.
+func (t *ZMQTransport) publisher(address string) (*zmq.Socket, error) {
+	if publisher, ok := t.publishers[address]; ok {
+		return publisher, nil
+	}
+	socket, err := t.Context.NewSocket(zmq.PUB)
+	if err != nil {
+		return nil, errors.New("error al crear el socket PUB")
+	}
+	socket.SetLinger(0)
+	if err := socket.Bind("tcp://" + address); err != nil {
+		socket.Close()
+		return nil, errors.New("error al enlazar el socket PUB en " + address)
+	}
+	t.Logger.Printf("Canal de difusión enlazado en %s", address)
+	t.publishers[address] = socket
+	return socket, nil
+}
+
+// Subscribe conecta un socket SUB a la dirección y entrega al handler los mensajes difundidos hasta que
+// se cierra el transporte.
+func (t *ZMQTransport) Subscribe(address string, handler func(message string)) error {
+	socket, err := t.Context.NewSocket(zmq.SUB)
+	if err != nil {
+		return errors.New("error al crear el socket SUB")
+	}
+	if err := socket.SetSubscribe(""); err != nil {
+		socket.Close()
+		return errors.New("error al suscribirse a " + address)
+	}
+	if err := socket.Connect("tcp://" + address); err != nil {
+		socket.Close()
+		return errors.New("error al conectar con " + address)
+	}
+
+	go func() {
+		defer socket.Close()
+		for {
+			message, err := socket.Recv(0)
+			if err != nil {
+				// Al terminar el contexto la recepción falla y la suscripción termina
+				t.Logger.Printf("Fin de la suscripción a %s: %v", address, err)
+				return
+			}
+			handler(message)
+		}
+	}()
+	return nil
+}
+
+// Drop cierra el socket REQ abierto hacia una dirección, si lo había.
+func (t *ZMQTransport) Drop(address string) {
+	t.connections.drop(address)
+}
+
+// Close cierra los sockets abiertos y termina el contexto, lo que detiene la escucha.
+func (t *ZMQTransport) Close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closed {
+		return nil
+	}
+	t.closed = true
+
+	// Los sockets abiertos hacia otros nodos se cierran antes de terminar el contexto, que si no espera por ellos
+	t.connections.close()
+	for address, publisher := range t.publishers {
+		publisher.Close()
+		delete(t.publishers, address)
+	}
+	return t.Context.Term()
+}
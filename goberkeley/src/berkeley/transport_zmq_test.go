@@ -0,0 +1,31 @@
+//go:build !nozmq
+
+package berkeley
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitEnvelope(t *testing.T) {
+	tests := []struct {
+		name     string
+		parts    []string
+		envelope []string
+		body     string
+	}{
+		{name: "vacío", parts: nil, envelope: nil, body: ""},
+		{name: "sólo cuerpo", parts: []string{"hola"}, envelope: []string{}, body: "hola"},
+		{name: "REQ a través de ROUTER", parts: []string{"id", "", "hola"}, envelope: []string{"id", ""}, body: "hola"},
+		{name: "varios saltos", parts: []string{"id1", "id2", "", "hola"}, envelope: []string{"id1", "id2", ""}, body: "hola"},
+		{name: "cuerpo vacío", parts: []string{"id", "", ""}, envelope: []string{"id", ""}, body: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			envelope, body := splitEnvelope(tt.parts)
+			if !reflect.DeepEqual(envelope, tt.envelope) || body != tt.body {
+				t.Errorf("splitEnvelope(%q) = %q, %q; se esperaba %q, %q", tt.parts, envelope, body, tt.envelope, tt.body)
+			}
+		})
+	}
+}
@@ -0,0 +1,149 @@
+package berkeley
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Las peticiones GET_TIME pueden viajar por UDP, en la misma dirección host:puerto del nodo, para que
+// el intercambio de tiempo no sufra el establecimiento de conexión ni el encapsulado del transporte.
+// Cada datagrama lleva un identificador de sonda, un salto de línea y el mensaje JSON; la respuesta
+// repite el identificador, de modo que una respuesta tardía a una sonda anterior se descarta. Sólo se
+// atiende GET_TIME: las correcciones y el cierre siguen por el transporte, que es fiable.
+
+// maxProbeSize es el mayor datagrama de sonda que se lee.
+const maxProbeSize = 64 * 1024
+
+// probeSeq numera las sondas enviadas por el proceso.
+var probeSeq uint64
+
+// StartProbeListener atiende por UDP, en la dirección del nodo, las peticiones GET_TIME con el Handler
+// del nodo. El resto de operaciones se rechazan.
+func (n *AbstractNode) StartProbeListener() error {
+	addr, err := net.ResolveUDPAddr("udp", n.Address)
+	if err != nil {
+		return fmt.Errorf("dirección UDP no válida %s: %w", n.Address, err)
+	}
+	conn, err := net.ListenUDP("udp", addr)
+	if err != nil {
+		n.Logger.Printf("Error al escuchar sondas UDP en %s: %v", n.Address, err)
+		return errors.New("error al enlazar el socket UDP en " + n.Address)
+	}
+	n.probeConn = conn
+
+	n.Logger.Printf("Nodo %s atendiendo sondas de tiempo UDP en %s", n.Name, n.Address)
+	go n.serveProbes(conn)
+	return nil
+}
+
+// serveProbes atiende los datagramas de sonda hasta que se cierra el socket. Cada datagrama se atiende
+// en su propia goroutine, de modo que una sonda lenta no retrasa a las que llegan detrás y agotan su
+// espera, con como mucho Workers sondas a la vez, como los mensajes del transporte. El Handler debe
+// admitir llamadas concurrentes.
+func (n *AbstractNode) serveProbes(conn *net.UDPConn) {
+	workers := n.Workers
+	if workers <= 0 {
+		workers = DefaultListenerWorkers
+	}
+	slots := make(chan struct{}, workers)
+
+	buffer := make([]byte, maxProbeSize)
+	for {
+		size, from, err := conn.ReadFromUDP(buffer)
+		if err != nil {
+			// Al cerrar el nodo se cierra el socket y la lectura falla
+			n.Logger.Printf("Fin de las sondas UDP en %s: %v", n.Address, err)
+			return
+		}
+		// Con todos los trabajadores ocupados los datagramas esperan en el búfer del socket
+		slots <- struct{}{}
+		go func(datagram string) {
+			defer func() { <-slots }()
+			n.serveProbe(conn, from, datagram)
+		}(string(buffer[:size]))
+	}
+}
+
+// serveProbe responde a un datagrama de sonda recibido de from.
+func (n *AbstractNode) serveProbe(conn *net.UDPConn, from *net.UDPAddr, datagram string) {
+	id, message, ok := strings.Cut(datagram, "\n")
+	if !ok {
+		return
+	}
+
+	var request struct {
+		Operation string `json:"operation"`
+	}
+	if err := json.Unmarshal([]byte(message), &request); err != nil || request.Operation != "GET_TIME" {
+		n.Logger.Printf("Sonda UDP de %s descartada: sólo se admite GET_TIME", from)
+		return
+	}
+
+	response := handleMessage(n.Handler, message)
+	if _, err := conn.WriteToUDP([]byte(id+"\n"+response), from); err != nil {
+		n.Logger.Printf("Error al responder la sonda UDP de %s: %v", from, err)
+	}
+}
+
+// sendProbe envía por UDP el mensaje que construye build a la dirección y espera la respuesta hasta
+// ProbeTimeout. Un datagrama perdido cuenta como un intento fallido y se repite de inmediato con un
+// mensaje nuevo, hasta los intentos de la RetryPolicy o hasta que se cancela el contexto. Devuelve la
+// respuesta y el número de intentos.
+func (n *AbstractNode) sendProbe(ctx context.Context, address string, build func(attempt int) (string, error)) (string, int, error) {
+	addr, err := net.ResolveUDPAddr("udp", address)
+	if err != nil {
+		return "", 0, fmt.Errorf("dirección UDP no válida %s: %w", address, err)
+	}
+	conn, err := net.DialUDP("udp", nil, addr)
+	if err != nil {
+		return "", 0, errors.New("error al conectar por UDP con " + address)
+	}
+	defer conn.Close()
+
+	attempts := n.RetryPolicy.Attempts
+	if attempts <= 0 {
+		attempts = 1
+	}
+	buffer := make([]byte, maxProbeSize)
+	for attempt := 1; attempt <= attempts; attempt++ {
+		if err := ctx.Err(); err != nil {
+			return "", attempt - 1, fmt.Errorf("sondas UDP a %s canceladas: %w", address, err)
+		}
+		message, err := build(attempt)
+		if err != nil {
+			return "", attempt, err
+		}
+		id := fmt.Sprintf("%d", atomic.AddUint64(&probeSeq, 1))
+		if _, err := conn.Write([]byte(id + "\n" + message)); err != nil {
+			return "", attempt, errors.New("error al enviar la sonda UDP")
+		}
+
+		if reply, ok := readProbeReply(conn, buffer, id, n.ProbeTimeout); ok {
+			return reply, attempt, nil
+		}
+		n.Logger.Printf("Sonda UDP %d de %d a %s sin respuesta en %v", attempt, attempts, address, n.ProbeTimeout)
+	}
+	return "", attempts, fmt.Errorf("sin respuesta UDP de %s tras %d sondas", address, attempts)
+}
+
+// readProbeReply lee datagramas hasta recibir la respuesta a la sonda id o agotar el tiempo. Las
+// respuestas a sondas anteriores se descartan.
+func readProbeReply(conn *net.UDPConn, buffer []byte, id string, timeout time.Duration) (string, bool) {
+	conn.SetReadDeadline(time.Now().Add(timeout))
+	for {
+		size, err := conn.Read(buffer)
+		if err != nil {
+			return "", false
+		}
+		replyID, reply, ok := strings.Cut(string(buffer[:size]), "\n")
+		if ok && replyID == id {
+			return reply, true
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package berkeley
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// freeUDPAddress devuelve una dirección local con un puerto UDP libre.
+func freeUDPAddress(t *testing.T) string {
+	t.Helper()
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	return conn.LocalAddr().String()
+}
+
+func TestProbeRoundTrip(t *testing.T) {
+	network := NewMemoryNetwork()
+	address := freeUDPAddress(t)
+
+	server := NewAbstractNodeWithTransport("S", address, 1000, network.Transport(time.Second))
+	server.Handler = echoHandler{}
+	server.Workers = 1
+	if err := server.StartProbeListener(); err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	client := NewAbstractNodeWithTransport("C", "memory:c", 1000, network.Transport(time.Second))
+	client.ProbeTimeout = time.Second
+	defer client.Close()
+
+	build := func(attempt int) (string, error) { return `{"operation":"GET_TIME"}`, nil }
+	reply, attempts, err := client.sendProbe(context.Background(), address, build)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `eco {"operation":"GET_TIME"}`; reply != want || attempts != 1 {
+		t.Errorf("respuesta %q en %d intentos, se esperaba %q en 1", reply, attempts, want)
+	}
+
+	// Una sonda con el contexto ya cancelado no se envía
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, attempts, err := client.sendProbe(ctx, address, build); err == nil || attempts != 0 {
+		t.Errorf("sonda cancelada: %d intentos, error %v", attempts, err)
+	}
+}
+
+func TestFollowerProbeListenerFailureStopsListening(t *testing.T) {
+	factory, err := NewTransportFactory(MemoryTransportName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous := DefaultTransport
+	DefaultTransport = factory
+	defer func() { DefaultTransport = previous }()
+
+	// La dirección en memoria no es una dirección UDP: las sondas no pueden escuchar en ella
+	follower, err := NewFollower("F", "memory:f", "memory:leader", 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	follower.UDPProbes = true
+	if err := follower.StartAlgorithm(); err == nil || !strings.Contains(err.Error(), "UDP") {
+		t.Fatalf("StartAlgorithm devolvió %v, se esperaba un error de las sondas UDP", err)
+	}
+
+	// El seguidor fallido ha dejado libre su dirección en el transporte
+	other, err := NewFollower("F2", "memory:f", "memory:leader", 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer other.aAbstractNode.Close()
+	if err := other.StartAlgorithm(); err != nil {
+		t.Errorf("la dirección sigue ocupada: %v", err)
+	}
+}
@@ -0,0 +1,178 @@
+package berkeley
+
+import (
+	"sync"
+	"time"
+)
+
+// VirtualClock es un reloj corregido que se apoya en otro reloj (Clock), por defecto el del sistema,
+// sin modificarlo. Acumula las correcciones de paso (Adjust), las graduales (Slew) y las de frecuencia
+// (AdjustRate) que recibe el nodo, de modo que todas las lecturas posteriores devuelven la hora ya
+// corregida. Es seguro para uso concurrente: el código que incrusta un nodo puede leerlo mientras el
+// nodo lo ajusta. VirtualClock también implementa Clock.
+type VirtualClock struct {
+	mu        sync.Mutex
+	base      Clock         // Reloj sobre el que se aplican las correcciones
+	offset    time.Duration // Correcciones de paso acumuladas
+	rate      float64       // Corrección de frecuencia acumulada en partes por millón
+	rateBase  time.Duration // Lectura monotónica desde la que corre la corrección de frecuencia actual
+	rateDrift time.Duration // Desplazamiento acumulado por las correcciones de frecuencia anteriores
+	slewLeft  time.Duration // Corrección gradual pendiente de aplicar desde slewBase
+	slewRate  float64       // Velocidad de la corrección gradual en partes por millón
+	slewBase  time.Duration // Lectura monotónica desde la que se aplica la corrección gradual pendiente
+}
+
+// DefaultMaxSlewRate es la velocidad máxima de corrección gradual por defecto, en partes por millón,
+// la misma que usa ntpd: 500 ppm corrigen medio milisegundo por cada segundo.
+const DefaultMaxSlewRate = 500.0
+
+// NewVirtualClock crea un reloj corregido sin correcciones sobre el reloj base indicado.
+// Con un reloj base nil se usa el reloj del sistema.
+func NewVirtualClock(base Clock) *VirtualClock {
+	if base == nil {
+		base = SystemClock{}
+	}
+	return &VirtualClock{base: base}
+}
+
+// virtualClockFor devuelve el reloj corregido de un nodo: el propio reloj si ya es un VirtualClock,
+// para que varios componentes puedan compartirlo, o uno nuevo construido sobre él.
+func virtualClockFor(clock Clock) *VirtualClock {
+	if vc, ok := clock.(*VirtualClock); ok {
+		return vc
+	}
+	return NewVirtualClock(clock)
+}
+
+// Now devuelve la hora corregida.
+func (c *VirtualClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.base.Now().Add(c.correctionAt(c.base.Monotonic()))
+}
+
+// NowMilli devuelve la hora corregida en milisegundos desde la época Unix.
+func (c *VirtualClock) NowMilli() int64 {
+	return c.Now().UnixMilli()
+}
+
+// Monotonic devuelve la lectura monotónica del reloj base, que no incluye ninguna corrección.
+func (c *VirtualClock) Monotonic() time.Duration {
+	return c.base.Monotonic()
+}
+
+// Adjust aplica una corrección de paso: adelanta (delta positivo) o retrasa (delta negativo) el reloj.
+func (c *VirtualClock) Adjust(delta time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.offset += delta
+}
+
+// Slew reparte la corrección delta en el tiempo en lugar de aplicarla de golpe: el reloj avanza más
+// deprisa (delta positivo) o más despacio (delta negativo) a razón de maxRate partes por millón hasta
+// completarla. Como maxRate es menor que un millón, el reloj nunca retrocede. Si ya había una corrección
+// gradual en curso, la nueva la sustituye: delta se calcula con el reloj ya corregido por lo aplicado
+// hasta ahora, así que sumarle lo que faltaba lo aplicaría dos veces.
+func (c *VirtualClock) Slew(delta time.Duration, maxRate float64) {
+	if maxRate <= 0 || maxRate >= 1e6 {
+		maxRate = DefaultMaxSlewRate
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.base.Monotonic()
+	c.stopSlewAt(now)
+	c.slewLeft = delta
+	c.slewRate = maxRate
+	c.slewBase = now
+}
+
+// StopSlew abandona la corrección gradual en curso: lo ya aplicado se conserva y el resto se descarta.
+// Devuelve la parte descartada.
+func (c *VirtualClock) StopSlew() time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.stopSlewAt(c.base.Monotonic())
+}
+
+// SlewRemaining devuelve la parte de la corrección gradual que aún falta por aplicar.
+func (c *VirtualClock) SlewRemaining() time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.slewLeft - c.slewAppliedAt(c.base.Monotonic())
+}
+
+// StepOffset devuelve las correcciones de paso y graduales que el reloj aplica ahora, sin la de
+// frecuencia ni lo que falta de la corrección gradual en curso.
+func (c *VirtualClock) StepOffset() time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.offset + c.slewAppliedAt(c.base.Monotonic())
+}
+
+// AdjustRate suma una corrección de frecuencia, en partes por millón, a la que el reloj ya aplicaba.
+// Lo que la corrección anterior había desplazado el reloj hasta ahora se conserva.
+func (c *VirtualClock) AdjustRate(ppm float64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.base.Monotonic()
+	c.rateDrift = c.rateDriftAt(now)
+	c.rateBase = now
+	c.rate += ppm
+}
+
+// Offset devuelve la corrección total que el reloj aplica ahora sobre el reloj base.
+func (c *VirtualClock) Offset() time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.correctionAt(c.base.Monotonic())
+}
+
+// Rate devuelve la corrección de frecuencia acumulada en partes por millón.
+func (c *VirtualClock) Rate() float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.rate
+}
+
+// correctionAt calcula la corrección total en la lectura monotónica now. Requiere el mutex bloqueado.
+func (c *VirtualClock) correctionAt(now time.Duration) time.Duration {
+	return c.offset + c.rateDriftAt(now) + c.slewAppliedAt(now)
+}
+
+// stopSlewAt pasa a offset la parte aplicada de la corrección gradual en la lectura monotónica now y
+// descarta el resto, que devuelve. Requiere el mutex bloqueado.
+func (c *VirtualClock) stopSlewAt(now time.Duration) time.Duration {
+	applied := c.slewAppliedAt(now)
+	c.offset += applied
+	dropped := c.slewLeft - applied
+	c.slewLeft = 0
+	return dropped
+}
+
+// slewAppliedAt calcula qué parte de la corrección gradual pendiente se ha aplicado en la lectura
+// monotónica now. Requiere el mutex bloqueado.
+func (c *VirtualClock) slewAppliedAt(now time.Duration) time.Duration {
+	if c.slewLeft == 0 {
+		return 0
+	}
+	applied := time.Duration(c.slewRate * 1e-6 * float64(now-c.slewBase))
+	if c.slewLeft < 0 {
+		if -applied < c.slewLeft {
+			return c.slewLeft
+		}
+		return -applied
+	}
+	if applied > c.slewLeft {
+		return c.slewLeft
+	}
+	return applied
+}
+
+// rateDriftAt calcula el desplazamiento acumulado por la frecuencia en la lectura monotónica now.
+// Requiere el mutex bloqueado.
+func (c *VirtualClock) rateDriftAt(now time.Duration) time.Duration {
+	if c.rate == 0 {
+		return c.rateDrift
+	}
+	return c.rateDrift + time.Duration(c.rate*1e-6*float64(now-c.rateBase))
+}
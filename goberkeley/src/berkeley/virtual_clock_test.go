@@ -0,0 +1,74 @@
+package berkeley
+
+import (
+	"testing"
+	"time"
+)
+
+func TestVirtualClockSlewReplacesPending(t *testing.T) {
+	base := NewManualClock(time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC))
+	clock := NewVirtualClock(base)
+
+	// A 500 ppm, diez segundos aplican 5 ms de los 10 pedidos
+	clock.Slew(-10*time.Millisecond, 500)
+	base.Advance(10 * time.Second)
+	if got := clock.Offset(); got != -5*time.Millisecond {
+		t.Fatalf("corrección aplicada %v, se esperaba -5ms", got)
+	}
+
+	// La nueva corrección sustituye a lo que faltaba de la anterior
+	clock.Slew(-2*time.Millisecond, 500)
+	if got := clock.SlewRemaining(); got != -2*time.Millisecond {
+		t.Errorf("corrección pendiente %v, se esperaba -2ms", got)
+	}
+	base.Advance(10 * time.Second)
+	if got := clock.Offset(); got != -7*time.Millisecond {
+		t.Errorf("corrección total %v, se esperaba -7ms", got)
+	}
+	if got := clock.StepOffset(); got != -7*time.Millisecond {
+		t.Errorf("corrección en vigor %v, se esperaba -7ms", got)
+	}
+
+	// StopSlew conserva lo aplicado y devuelve lo descartado
+	clock.Slew(4*time.Millisecond, 500)
+	base.Advance(2 * time.Second)
+	if dropped := clock.StopSlew(); dropped != 3*time.Millisecond {
+		t.Errorf("corrección descartada %v, se esperaba 3ms", dropped)
+	}
+	if got := clock.Offset(); got != -6*time.Millisecond {
+		t.Errorf("corrección total %v, se esperaba -6ms", got)
+	}
+}
+
+func TestVirtualClockRateCorrection(t *testing.T) {
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	base := NewManualClock(start)
+	clock := NewVirtualClock(base)
+
+	// A 100 ppm, diez segundos desplazan el reloj 1 ms; la corrección de paso se suma
+	clock.AdjustRate(100)
+	clock.Adjust(-3 * time.Millisecond)
+	base.Advance(10 * time.Second)
+	if got := clock.Offset(); !closeTo(got, -2*time.Millisecond) {
+		t.Errorf("corrección total %v, se esperaba -2ms", got)
+	}
+	if got, want := clock.Now(), start.Add(10*time.Second-2*time.Millisecond); !closeTo(got.Sub(want), 0) {
+		t.Errorf("hora corregida %v, se esperaba %v", got, want)
+	}
+
+	// Una segunda corrección de frecuencia conserva lo que desplazó la primera
+	clock.AdjustRate(-100)
+	base.Advance(10 * time.Second)
+	if got := clock.Offset(); !closeTo(got, -2*time.Millisecond) {
+		t.Errorf("corrección total %v tras anular la frecuencia, se esperaba -2ms", got)
+	}
+	if got := clock.Rate(); got != 0 {
+		t.Errorf("frecuencia acumulada %.3f ppm, se esperaba 0", got)
+	}
+}
+
+// closeTo indica si got está a menos de un microsegundo de want, el redondeo de las correcciones de frecuencia.
+func closeTo(got, want time.Duration) bool {
+	diff := got - want
+	return diff > -time.Microsecond && diff < time.Microsecond
+}
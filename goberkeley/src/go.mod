@@ -5,6 +5,5 @@ go 1.22.2
 require (
 	github.com/pebbe/zmq4 v1.2.11
 	github.com/sirupsen/logrus v1.9.3
+	golang.org/x/sys v0.0.0-20220715151400-c0bba94af5f8
 )
-
-require golang.org/x/sys v0.0.0-20220715151400-c0bba94af5f8 // indirect
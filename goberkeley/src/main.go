@@ -1,9 +1,13 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"goberkeley/berkeley"
 	"log"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 )
 
@@ -14,52 +18,113 @@ func main() {
 	configFile := "config.json"
 	config, err := loadConfig(configFile)
 	if err != nil {
-		log.Fatalf("Error al cargar la configuración: %v", err)
+		log.Fatalf("Error al cargar la configuración: %s", *err)
 	}
 
-	// Mostrar las direcciones de los seguidores
+	// Transporte por el que se comunican todos los nodos
+	transport, err_transport := berkeley.NewTransportFactory(config.Transport)
+	if err_transport != nil {
+		log.Fatalf("Error al seleccionar el transporte: %v", err_transport)
+	}
+	berkeley.DefaultTransport = transport
+	if config.AsyncPortOffset != 0 {
+		berkeley.AsyncPortOffset = config.AsyncPortOffset
+	}
+
+	// Modos sin líder: todos los nodos sincronizan sus relojes por gossip o por convergencia interactiva
+	if config.Algorithm == berkeley.GossipAlgorithm || config.Algorithm == berkeley.InteractiveConvergenceAlgorithm {
+		runLeaderless(config)
+		return
+	}
+
+	// Mostrar las direcciones de los seguidores; los sub-líderes son seguidores del líder principal
 	followerAddresses := make(map[string]string)
 	for _, follower := range config.Followers {
 		followerAddresses[follower.Name] = follower.Address
 		log.Printf("Dirección: %s, Nombre: %s", follower.Address, follower.Name)
 	}
+	for _, subLeader := range config.SubLeaders {
+		followerAddresses[subLeader.Name] = subLeader.Address
+		log.Printf("Dirección: %s, Nombre: %s (sub-líder)", subLeader.Address, subLeader.Name)
+	}
 
 	// Crear el líder
 	var leader *berkeley.Leader
-	leader, err_leader := berkeley.InitializeLeaderNode(config.Leader.Address, config.Leader.Name, config.Timeout, followerAddresses)
+	leader, err_leader := berkeley.InitializeLeaderNode(config.Leader.Name, config.Leader.Address, config.Timeout, followerAddresses)
 	if err_leader != nil {
 		// Maneja el error adecuadamente
 		fmt.Println("Error al inicializar el nodo líder:", err_leader)
 		return // O maneja el error de otra manera
 	}
+	configureLeader(leader, config)
+	leader.Priority = config.Leader.Priority
+	leader.BroadcastAddress = config.Leader.BroadcastAddress
 	log.Printf("Líder %s inicializado en dirección %s", config.Leader.Name, config.Leader.Address)
 
+	// Miembros del grupo para la elección de líder: el líder actual, los seguidores y los sub-líderes
+	members := []berkeley.Peer{{Name: config.Leader.Name, Address: config.Leader.Address, Priority: config.Leader.Priority}}
+	for _, followerConfig := range config.Followers {
+		members = append(members, berkeley.Peer{Name: followerConfig.Name, Address: followerConfig.Address, Priority: followerConfig.Priority})
+	}
+	for _, subLeaderConfig := range config.SubLeaders {
+		members = append(members, berkeley.Peer{Name: subLeaderConfig.Name, Address: subLeaderConfig.Address, Priority: subLeaderConfig.Priority})
+	}
+
 	// Crear los seguidores
 	for _, followerConfig := range config.Followers {
-		var follower *berkeley.Follower
-		follower, err := berkeley.InitializeFollowerNode(followerConfig.Name, followerConfig.Address, config.Leader.Address, config.Timeout)
+		startFollower(followerConfig, config.Leader.Address, config.Leader.BroadcastAddress, members, config)
+	}
+
+	// Crear los sub-líderes y los seguidores de sus grupos
+	for _, subLeaderConfig := range config.SubLeaders {
+		groupAddresses := make(map[string]string)
+		group := []berkeley.Peer{{Name: subLeaderConfig.Name, Address: subLeaderConfig.Address, Priority: subLeaderConfig.Priority}}
+		for _, followerConfig := range subLeaderConfig.Followers {
+			groupAddresses[followerConfig.Name] = followerConfig.Address
+			group = append(group, berkeley.Peer{Name: followerConfig.Name, Address: followerConfig.Address, Priority: followerConfig.Priority})
+		}
 
+		subLeader, err := berkeley.NewSubLeader(subLeaderConfig.Name, subLeaderConfig.Address, config.Leader.Address, config.Timeout, groupAddresses, berkeley.SystemClock{})
 		if err != nil {
-			log.Fatalf("Error al inicializar el seguidor %s: %v", followerConfig.Name, err)
+			log.Fatalf("Error al inicializar el sub-líder %s: %v", subLeaderConfig.Name, err)
+		}
+		configureLeader(subLeader.Group(), config)
+		subLeader.Group().BroadcastAddress = subLeaderConfig.BroadcastAddress
+		configureFollower(subLeader.Follower(), subLeaderConfig.Name, subLeaderConfig.Priority, members, config)
+		// El sub-líder responde al GET_TIME tras la ronda de su grupo, que no cabe en la espera de una sonda
+		subLeader.Follower().UDPProbes = false
+		subLeader.Follower().BroadcastAddress = config.Leader.BroadcastAddress
+		log.Printf("Sub-líder %s inicializado en dirección %s", subLeaderConfig.Name, subLeaderConfig.Address)
+
+		for _, followerConfig := range subLeaderConfig.Followers {
+			startFollower(followerConfig, subLeaderConfig.Address, subLeaderConfig.BroadcastAddress, group, config)
 		}
-		log.Printf("Seguidor %s inicializado en dirección %s", followerConfig.Name, followerConfig.Address)
 
-		go func(followerName string) {
-			log.Printf("Iniciando algoritmo para el seguidor %s", followerName)
-			err := follower.StartAlgorithm()
-			if err != nil {
-				log.Printf("Error al iniciar el algoritmo del seguidor %s: %v", followerName, err)
+		go func(subLeaderName string) {
+			log.Printf("Iniciando algoritmo para el sub-líder %s", subLeaderName)
+			if err := subLeader.StartAlgorithm(); err != nil {
+				log.Printf("Error al iniciar el algoritmo del sub-líder %s: %v", subLeaderName, err)
 			}
-		}(followerConfig.Name)
+		}(subLeaderConfig.Name)
 	}
 
 	// Esperar 2 segundos para asegurar que los seguidores estén listos
 	time.Sleep(2 * time.Second)
 	log.Println("Esperando 2 segundos para asegurar que los seguidores estén listos.")
 
-	// Iniciar el algoritmo del líder
-	log.Println("Iniciando algoritmo del líder.")
-	leader.StartAlgorithm()
+	if config.SyncInterval > 0 {
+		// Modo periódico: repetir rondas hasta recibir una señal de parada
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		log.Printf("Iniciando algoritmo periódico del líder cada %d ms.", config.SyncInterval)
+		if err := leader.RunPeriodic(ctx, config.SyncInterval*time.Millisecond); err != nil {
+			log.Printf("Error en el algoritmo periódico del líder: %v", err)
+		}
+	} else {
+		// Iniciar el algoritmo del líder
+		log.Println("Iniciando algoritmo del líder.")
+		leader.StartAlgorithm()
+	}
 
 	// Esperar que el líder reciba respuestas de los seguidores
 	time.Sleep(time.Duration(config.Timeout+200) * time.Millisecond)
@@ -71,10 +136,203 @@ func main() {
 	log.Println("Nodo líder cerrado correctamente.")
 }
 
+// configureLeader aplica al líder los parámetros del algoritmo de la configuración.
+func configureLeader(leader *berkeley.Leader, config *berkeley.Config) {
+	leader.OutlierThreshold = time.Duration(config.OutlierThreshold) * time.Millisecond
+	aggregator, err_aggregator := berkeley.NewAggregator(config.Aggregation, config.ReferenceNode, time.Duration(config.ConvergenceBound)*time.Millisecond)
+	if err_aggregator != nil {
+		log.Fatalf("Error al seleccionar la estrategia de agregación: %v", err_aggregator)
+	}
+	leader.Aggregator = aggregator
+	leader.SamplesPerFollower = config.Samples
+	leader.MaxRTT = time.Duration(config.MaxRTT) * time.Millisecond
+	leader.DriftWindow = config.DriftWindow
+	leader.SetRetryPolicy(retryPolicy(config))
+	leader.SetProbeTimeout(time.Duration(config.ProbeTimeout) * time.Millisecond)
+	leader.SubLeaders = make(map[string]bool)
+	for _, subLeaderConfig := range config.SubLeaders {
+		leader.SubLeaders[subLeaderConfig.Name] = true
+	}
+	// Parámetros de corrección que se difunden al grupo si el líder tiene canal de difusión
+	leader.Params = &berkeley.ClusterParams{
+		AdjustMode:  berkeley.AdjustMode(config.AdjustMode),
+		MaxSlewRate: config.MaxSlewRate,
+		StepLimit:   int64(time.Duration(config.StepLimit) * time.Millisecond),
+	}
+}
+
+// retryPolicy construye la política de reintentos de la configuración; los valores a cero conservan
+// los de la política por defecto.
+func retryPolicy(config *berkeley.Config) berkeley.RetryPolicy {
+	policy := berkeley.DefaultRetryPolicy
+	if config.Retries > 0 {
+		policy.Attempts = config.Retries
+	}
+	if config.RetryBackoff > 0 {
+		policy.Backoff = time.Duration(config.RetryBackoff) * time.Millisecond
+	}
+	return policy
+}
+
+// startFollower crea un seguidor del líder en leaderAddress, lo configura y lo pone a escuchar.
+// broadcastAddress es el canal de difusión de ese líder, vacío si no difunde. members son los nodos
+// de su grupo que participan en la elección de líder.
+func startFollower(followerConfig berkeley.FollowerConfig, leaderAddress, broadcastAddress string, members []berkeley.Peer, config *berkeley.Config) {
+	follower, err := berkeley.NewFollowerWithClock(followerConfig.Name, followerConfig.Address, leaderAddress, config.Timeout, followerClock(followerConfig))
+
+	if err != nil {
+		log.Fatalf("Error al inicializar el seguidor %s: %v", followerConfig.Name, err)
+	}
+	configureFollower(follower, followerConfig.Name, followerConfig.Priority, members, config)
+	follower.BroadcastAddress = broadcastAddress
+	log.Printf("Seguidor %s inicializado en dirección %s", followerConfig.Name, followerConfig.Address)
+
+	go func(followerName string) {
+		log.Printf("Iniciando algoritmo para el seguidor %s", followerName)
+		err := follower.StartAlgorithm()
+		if err != nil {
+			log.Printf("Error al iniciar el algoritmo del seguidor %s: %v", followerName, err)
+		}
+	}(followerConfig.Name)
+}
+
+// followerClock devuelve el reloj del seguidor: el del sistema o, si la configuración lo pide, uno
+// simulado con desfase o deriva para observar la convergencia del algoritmo.
+func followerClock(followerConfig berkeley.FollowerConfig) berkeley.Clock {
+	if followerConfig.ClockOffset == 0 && followerConfig.ClockDrift == 0 {
+		return berkeley.SystemClock{}
+	}
+	log.Printf("Seguidor %s con reloj simulado: desfase %d ms, deriva %.1f ppm", followerConfig.Name, followerConfig.ClockOffset, followerConfig.ClockDrift)
+	return berkeley.NewSkewedClock(time.Duration(followerConfig.ClockOffset)*time.Millisecond, followerConfig.ClockDrift)
+}
+
+// leaderlessNode es un nodo de los modos sin líder (GossipNode o ConvergenceNode).
+type leaderlessNode interface {
+	StartAlgorithm() error
+	RunPeriodic(ctx context.Context, interval time.Duration) error
+	Close()
+}
+
+// runLeaderless ejecuta un modo sin líder: el líder y los seguidores de la configuración son pares
+// iguales que sincronizan sus relojes con el algoritmo configurado cada sync_interval hasta recibir
+// una señal de parada.
+func runLeaderless(config *berkeley.Config) {
+	if config.SyncInterval <= 0 {
+		log.Fatalf("El modo %s necesita un sync_interval mayor que cero", config.Algorithm)
+	}
+
+	// Todos los nodos son pares: el del líder se trata como uno más
+	nodeConfigs := append([]berkeley.FollowerConfig{{Name: config.Leader.Name, Address: config.Leader.Address}}, config.Followers...)
+	peers := make(map[string]string)
+	for _, nodeConfig := range nodeConfigs {
+		peers[nodeConfig.Name] = nodeConfig.Address
+	}
+
+	var nodes []leaderlessNode
+	for _, nodeConfig := range nodeConfigs {
+		var node leaderlessNode
+		var err error
+		if config.Algorithm == berkeley.GossipAlgorithm {
+			node, err = newGossipNode(nodeConfig, peers, config)
+		} else {
+			node, err = newConvergenceNode(nodeConfig, peers, config)
+		}
+		if err != nil {
+			log.Fatalf("Error al inicializar el nodo %s: %v", nodeConfig.Name, err)
+		}
+		if err := node.StartAlgorithm(); err != nil {
+			log.Fatalf("Error al iniciar el nodo %s: %v", nodeConfig.Name, err)
+		}
+		nodes = append(nodes, node)
+	}
+
+	// Esperar 2 segundos para asegurar que los nodos estén escuchando
+	time.Sleep(2 * time.Second)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	interval := config.SyncInterval * time.Millisecond
+	for i, node := range nodes {
+		go func(node leaderlessNode, delay time.Duration) {
+			// Escalonar el arranque para que las rondas de los nodos no coincidan
+			time.Sleep(delay)
+			if err := node.RunPeriodic(ctx, interval); err != nil {
+				log.Printf("Error en la sincronización %s: %v", config.Algorithm, err)
+			}
+		}(node, interval*time.Duration(i)/time.Duration(len(nodes)))
+	}
+
+	<-ctx.Done()
+	// Dejar terminar las rondas en curso antes de cerrar los nodos
+	time.Sleep(time.Duration(config.Timeout+200) * time.Millisecond)
+	for _, node := range nodes {
+		node.Close()
+	}
+	log.Printf("Nodos %s cerrados correctamente.", config.Algorithm)
+}
+
+// newGossipNode crea un nodo del modo gossip con los parámetros de la configuración.
+func newGossipNode(nodeConfig berkeley.FollowerConfig, peers map[string]string, config *berkeley.Config) (leaderlessNode, error) {
+	node, err := berkeley.NewGossipNode(nodeConfig.Name, nodeConfig.Address, config.Timeout, peers, followerClock(nodeConfig))
+	if err != nil {
+		return nil, err
+	}
+	node.Fanout = config.GossipFanout
+	node.Gain = config.GossipGain
+	node.SamplesPerPeer = config.Samples
+	node.MaxRTT = time.Duration(config.MaxRTT) * time.Millisecond
+	node.AdjustMode = berkeley.AdjustMode(config.AdjustMode)
+	node.MaxSlewRate = config.MaxSlewRate
+	return node, nil
+}
+
+// newConvergenceNode crea un nodo de convergencia interactiva con los parámetros de la configuración.
+func newConvergenceNode(nodeConfig berkeley.FollowerConfig, peers map[string]string, config *berkeley.Config) (leaderlessNode, error) {
+	node, err := berkeley.NewConvergenceNode(nodeConfig.Name, nodeConfig.Address, config.Timeout, peers, followerClock(nodeConfig))
+	if err != nil {
+		return nil, err
+	}
+	node.Bound = time.Duration(config.ConvergenceBound) * time.Millisecond
+	node.SamplesPerPeer = config.Samples
+	node.MaxRTT = time.Duration(config.MaxRTT) * time.Millisecond
+	node.AdjustMode = berkeley.AdjustMode(config.AdjustMode)
+	node.MaxSlewRate = config.MaxSlewRate
+	return node, nil
+}
+
+// configureFollower aplica al seguidor los parámetros de corrección y de elección de la configuración.
+func configureFollower(follower *berkeley.Follower, name string, priority int, members []berkeley.Peer, config *berkeley.Config) {
+	var err error
+	follower.AdjustMode = berkeley.AdjustMode(config.AdjustMode)
+	follower.MaxSlewRate = config.MaxSlewRate
+	follower.StepLimit = time.Duration(config.StepLimit) * time.Millisecond
+	follower.Adjuster, err = berkeley.NewClockAdjuster(config.SystemClock)
+	if err != nil {
+		log.Fatalf("No se puede modificar el reloj del sistema en el seguidor %s: %v", name, err)
+	}
+	follower.SetRetryPolicy(retryPolicy(config))
+	follower.UDPProbes = config.ProbeTimeout > 0
+	follower.Priority = priority
+	for _, member := range members {
+		if member.Name != name {
+			follower.Peers = append(follower.Peers, member)
+		}
+	}
+	follower.ElectionTimeout = config.ElectionTimeout * time.Millisecond
+	follower.SyncInterval = config.SyncInterval * time.Millisecond
+	follower.OnPromote = func(promoted *berkeley.Leader) {
+		configureLeader(promoted, config)
+	}
+}
+
 // Cargar la configuración desde el archivo JSON
 func loadConfig(filePath string) (config *berkeley.Config, error *string) {
 
 	config = berkeley.LoadConfig(filePath)
+	if config == nil {
+		message := "no se pudo cargar " + filePath
+		return nil, &message
+	}
 
 	return config, nil
 }